@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The kinds of rule an achievement can be earned by
+const (
+	// Answer Count challenges correctly
+	CorrectRule = "correct"
+	// Answer Count challenges correctly in a row, without a wrong answer or an expired challenge
+	StreakRule = "streak"
+	// Answer challenges sent to Count different subnets (0 means every subnet)
+	SubnetsRule = "subnets"
+	// Get an answer back within Seconds of receiving the challenge
+	RoundTripRule = "round_trip"
+	// Hold the room's record for the fastest round-trip when the game ends
+	FastestRule = "fastest"
+	// Get credit for looking up Count answers for other players
+	ResponderRule = "responder"
+)
+
+// Achievement is a declarative definition of a badge players can earn
+type Achievement struct {
+	// Identifies the achievement (unique per room)
+	ID string `json:"id"`
+
+	// Shown to players
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// The badge shown on the scoreboard (usually an emoji)
+	Badge string `json:"badge"`
+
+	// The kind of rule (see CorrectRule, etc.)
+	Rule string `json:"rule"`
+
+	// The number the rule needs to reach
+	Count int `json:"count,omitempty"`
+
+	// The time limit (for round_trip rules)
+	Seconds float64 `json:"seconds,omitempty"`
+}
+
+// DefaultAchievements returns the achievements used by newly created rooms
+func DefaultAchievements() []Achievement {
+	return []Achievement{
+		{ID: "first-contact", Name: "First Contact", Description: "Answer your first challenge", Badge: "🎉", Rule: CorrectRule, Count: 1},
+		{ID: "on-a-roll", Name: "On a Roll", Description: "Answer 10 challenges in a row", Badge: "🔥", Rule: StreakRule, Count: 10},
+		{ID: "world-traveller", Name: "World Traveller", Description: "Answer challenges sent to every subnet", Badge: "🌐", Rule: SubnetsRule},
+		{ID: "speed-of-light", Name: "Speed of Light", Description: "Have the fastest round-trip when the game ends", Badge: "⚡", Rule: FastestRule},
+		{ID: "good-neighbour", Name: "Good Neighbour", Description: "Look up 10 answers for other players", Badge: "🤝", Rule: ResponderRule, Count: 10},
+	}
+}
+
+// validateAchievements checks that every achievement can be earned
+func (opts RoomOptions) validateAchievements() error {
+	ids := make(map[string]bool)
+	for _, achievement := range opts.Achievements {
+		if achievement.ID == "" || ids[achievement.ID] {
+			return fmt.Errorf("achievements need a unique id (got %q)", achievement.ID)
+		}
+		ids[achievement.ID] = true
+
+		switch achievement.Rule {
+		case CorrectRule, StreakRule, ResponderRule:
+			if achievement.Count <= 0 {
+				return fmt.Errorf("achievement %q needs a positive count", achievement.ID)
+			}
+		case SubnetsRule:
+			if achievement.Count < 0 {
+				return fmt.Errorf("achievement %q needs a count of at least 0", achievement.ID)
+			}
+		case RoundTripRule:
+			if achievement.Seconds <= 0 {
+				return fmt.Errorf("achievement %q needs a positive time limit", achievement.ID)
+			}
+		case FastestRule:
+		default:
+			return fmt.Errorf("achievement %q has an unknown rule %q", achievement.ID, achievement.Rule)
+		}
+	}
+	return nil
+}
+
+// Badge is an achievement a player earned
+type Badge struct {
+	// The achievement's ID
+	ID string `json:"id"`
+
+	// Copied from the achievement, so the badge makes sense on its own
+	Name  string `json:"name"`
+	Badge string `json:"badge"`
+
+	// When the badge was earned
+	Earned time.Time `json:"earned"`
+}
+
+// PlayerStats are the numbers achievements are earned by
+type PlayerStats struct {
+	Correct   int
+	Streak    int
+	Subnets   int
+	RoundTrip time.Duration
+	Fastest   bool
+	Responded int
+}
+
+// Stats returns a player's stats
+//
+// Every player's stats are computed in a single pass over the room's challenges
+// and cached until a challenge or an address changes (see record and forgetStats).
+// The caller must hold the room's lock
+func (room *Room) Stats(name Name) PlayerStats {
+	// Readers share the room's lock, so the cache has a lock of its own
+	room.statsMu.Lock()
+	defer room.statsMu.Unlock()
+	if room.stats == nil {
+		room.stats = room.computeStats()
+	}
+	return room.stats[name]
+}
+
+// record stores a challenge's result. Every change to a challenge goes through
+// here, so cached stats are never stale
+//
+// The caller must hold the room's lock
+func (room *Room) record(challenge Challenge, result ChallengeResult) {
+	room.Challenges[challenge] = result
+	room.forgetStats()
+}
+
+// forgetStats drops the cached stats, after something they're computed from changed
+//
+// The caller must hold the room's lock
+func (room *Room) forgetStats() {
+	room.statsMu.Lock()
+	room.stats = nil
+	room.statsMu.Unlock()
+}
+
+// computeStats computes every player's stats from the room's challenges
+func (room *Room) computeStats() map[Name]PlayerStats {
+	stats := make(map[Name]PlayerStats)
+
+	// Each player's challenges, oldest first, so streaks can be counted. Including
+	// challenges sent from addresses the player was moved away from
+	type sent struct {
+		challenge Challenge
+		result    ChallengeResult
+	}
+	history := make(map[Name][]sent)
+	var best time.Duration
+	for challenge, result := range room.Challenges {
+		if result.Correct {
+			if trip := result.Answered.Sub(result.Created); best == 0 || trip < best {
+				best = trip
+			}
+		}
+
+		for _, credited := range result.Credited() {
+			if name, ok := room.nameOf(credited); ok {
+				s := stats[name]
+				s.Responded++
+				stats[name] = s
+			}
+		}
+
+		if source, err := ParseIP(challenge.SourceIP); err == nil {
+			if name, ok := room.nameOf(source); ok {
+				history[name] = append(history[name], sent{challenge, result})
+			}
+		}
+	}
+
+	for name, history := range history {
+		sort.Slice(history, func(i, j int) bool {
+			return history[i].result.Created.Before(history[j].result.Created)
+		})
+
+		s := stats[name]
+		subnets := make(map[int]bool)
+		streak := 0
+		for _, h := range history {
+			switch {
+			case h.result.Correct && h.result.Wrong == 0:
+				streak++
+			case h.result.Outstanding() || h.result.Refused:
+				continue
+			default:
+				streak = 0
+			}
+			s.Streak = max(s.Streak, streak)
+
+			if !h.result.Correct {
+				continue
+			}
+			s.Correct++
+			if dest, err := ParseIP(h.challenge.DestIP); err == nil {
+				subnets[dest.Subnet] = true
+			}
+			if trip := h.result.Answered.Sub(h.result.Created); s.RoundTrip == 0 || trip < s.RoundTrip {
+				s.RoundTrip = trip
+			}
+		}
+		s.Subnets = len(subnets)
+		s.Fastest = s.Correct > 0 && s.RoundTrip == best
+		stats[name] = s
+	}
+	return stats
+}
+
+// Earned returns true if the stats satisfy the achievement's rule
+func (achievement Achievement) Earned(stats PlayerStats, numSubnets int) bool {
+	switch achievement.Rule {
+	case CorrectRule:
+		return stats.Correct >= achievement.Count
+	case StreakRule:
+		return stats.Streak >= achievement.Count
+	case SubnetsRule:
+		if achievement.Count == 0 {
+			return stats.Subnets >= numSubnets
+		}
+		return stats.Subnets >= achievement.Count
+	case RoundTripRule:
+		return stats.Correct > 0 && stats.RoundTrip.Seconds() <= achievement.Seconds
+	case FastestRule:
+		return stats.Fastest
+	case ResponderRule:
+		return stats.Responded >= achievement.Count
+	}
+	return false
+}
+
+// CheckAchievements awards the players every achievement they just earned,
+// returning the clients to notify along with their new badges
+//
+// Records can still be broken, so they are awarded when the game ends (see AwardRecords).
+// The caller must hold the room's lock
+func (room *Room) CheckAchievements(names ...Name) map[*Client][]Badge {
+	awards := make(map[*Client][]Badge)
+	for _, client := range room.Clients {
+		if !containsName(names, client.Name) {
+			continue
+		}
+
+		stats := room.Stats(client.Name)
+		for _, achievement := range room.Options.Achievements {
+			if achievement.Rule == FastestRule || !achievement.Earned(stats, room.Metadata.NumSubnets) {
+				continue
+			}
+			if badge, ok := room.award(client.Name, achievement); ok {
+				awards[client] = append(awards[client], badge)
+			}
+		}
+	}
+	return awards
+}
+
+// AwardRecords awards the record achievements to whoever holds the record when
+// the game (or a tournament's round) ends, returning the clients to notify along
+// with their new badges. Players tied for the record share it
+//
+// The caller must hold the room's lock
+func (room *Room) AwardRecords() map[*Client][]Badge {
+	awards := make(map[*Client][]Badge)
+	for _, client := range room.Clients {
+		stats := room.Stats(client.Name)
+		for _, achievement := range room.Options.Achievements {
+			if achievement.Rule != FastestRule || !achievement.Earned(stats, room.Metadata.NumSubnets) {
+				continue
+			}
+			if badge, ok := room.award(client.Name, achievement); ok {
+				awards[client] = append(awards[client], badge)
+			}
+		}
+	}
+	return awards
+}
+
+// award gives a player an achievement's badge, unless they already have it
+//
+// The caller must hold the room's lock
+func (room *Room) award(name Name, achievement Achievement) (Badge, bool) {
+	if room.HasBadge(name, achievement.ID) {
+		return Badge{}, false
+	}
+
+	badge := Badge{
+		ID:     achievement.ID,
+		Name:   achievement.Name,
+		Badge:  achievement.Badge,
+		Earned: time.Now(),
+	}
+	room.Badges[name] = append(room.Badges[name], badge)
+	return badge, true
+}
+
+// HasBadge returns true if the player already earned the achievement
+func (room *Room) HasBadge(name Name, id string) bool {
+	for _, badge := range room.Badges[name] {
+		if badge.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// BadgeIcons returns the badges of every player, for the scoreboard
+func (room *Room) BadgeIcons() map[Name][]string {
+	icons := make(map[Name][]string)
+	for name, badges := range room.Badges {
+		for _, badge := range badges {
+			icons[name] = append(icons[name], badge.Badge)
+		}
+	}
+	return icons
+}
+
+// notifyBadges tells players about the badges they just earned
+func notifyBadges(awards map[*Client][]Badge) {
+	for client, badges := range awards {
+		for _, badge := range badges {
+			_ = client.Send(NewBadgeMessage(badge))
+		}
+	}
+}
+
+// Returns true if names contains name
+func containsName(names []Name, name Name) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
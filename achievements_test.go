@@ -0,0 +1,185 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	created := time.Now()
+	correct := ChallengeResult{Correct: true, Answered: created.Add(time.Second)}
+
+	tests := []struct {
+		name string
+		// Challenges sent by player 0 to the players with these indices, oldest first
+		sent    []ChallengeResult
+		dests   []int
+		want    PlayerStats
+		retired bool
+	}{
+		{"nothing sent", nil, nil, PlayerStats{}, false},
+		{"one correct", []ChallengeResult{correct}, []int{1}, PlayerStats{Correct: 1, Streak: 1, Subnets: 1, RoundTrip: time.Second, Fastest: true}, false},
+		{"streak broken by a wrong answer", []ChallengeResult{correct, {Wrong: 1, Expired: true}, correct, correct}, []int{1, 1, 2, 3}, PlayerStats{Correct: 3, Streak: 2, Subnets: 3, RoundTrip: time.Second, Fastest: true}, false},
+		{"outstanding challenges don't break streaks", []ChallengeResult{correct, {}, correct}, []int{1, 1, 1}, PlayerStats{Correct: 2, Streak: 2, Subnets: 1, RoundTrip: time.Second, Fastest: true}, false},
+		{"challenges sent from a retired address", []ChallengeResult{correct}, []int{1}, PlayerStats{Correct: 1, Streak: 1, Subnets: 1, RoundTrip: time.Second, Fastest: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 4, nil)
+			player := room.players[0]
+			source := room.ip(player)
+
+			for i, result := range tt.sent {
+				result.Created = created.Add(time.Duration(i) * time.Millisecond)
+				if result.Correct {
+					result.Answered = result.Created.Add(time.Second)
+				}
+				dest := room.ip(room.players[tt.dests[i]])
+				room.record(Challenge{ID: i, SourceIP: source.String(), DestIP: dest.String()}, result)
+			}
+			if tt.retired {
+				room.Lock()
+				if _, _, err := room.movePlayer(player.Name, 4); err != nil {
+					t.Fatalf("movePlayer: %v", err)
+				}
+				room.Unlock()
+			}
+
+			if got := room.Stats(player.Name); got != tt.want {
+				t.Errorf("Stats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsAreCached(t *testing.T) {
+	room := newTestRoom(t, 2, nil)
+	sender, dest := room.players[0], room.players[1]
+	challenge := Challenge{ID: 1, SourceIP: room.ip(sender).String(), DestIP: room.ip(dest).String()}
+	room.record(challenge, ChallengeResult{Correct: true, Confirmed: []IP{room.ip(dest)}})
+
+	tests := []struct {
+		name   string
+		change func()
+		want   int
+	}{
+		{"computed", func() {}, 1},
+		{"cached until something changes", func() { room.Challenges[challenge] = ChallengeResult{} }, 1},
+		{"recomputed after a challenge changes", func() { room.record(challenge, ChallengeResult{}) }, 0},
+		{"recomputed after an address changes", func() {
+			room.Challenges[challenge] = ChallengeResult{Correct: true, Confirmed: []IP{room.ip(dest)}}
+			room.leaveSubnet(dest.Name)
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			if got := room.Stats(dest.Name).Responded; got != tt.want {
+				t.Errorf("Responded = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFastestIsAwardedWhenTheGameEnds(t *testing.T) {
+	// Round-trips of each player's answers, in the order they were answered
+	tests := []struct {
+		name    string
+		trips   [][]time.Duration
+		holders []int
+	}{
+		{"nobody answered", nil, nil},
+		{"single record holder", [][]time.Duration{{3 * time.Second}, {2 * time.Second}, {time.Second}}, []int{2}},
+		{"an early record that was broken", [][]time.Duration{{time.Second}, {500 * time.Millisecond}}, []int{1}},
+		{"players tied for the record", [][]time.Duration{{time.Second}, {time.Second, 2 * time.Second}}, []int{0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 4, nil)
+			created := time.Now()
+			for i, trips := range tt.trips {
+				player := room.players[i]
+				for j, trip := range trips {
+					challenge := Challenge{ID: i*10 + j, SourceIP: room.ip(player).String(), DestIP: room.ip(room.players[3]).String()}
+					room.record(challenge, ChallengeResult{Created: created, Answered: created.Add(trip), Correct: true})
+
+					// The record can still be broken, so it isn't awarded while the game goes on
+					room.CheckAchievements(player.Name)
+					if room.HasBadge(player.Name, "speed-of-light") {
+						t.Fatalf("%s was awarded the record during the game", player.Name)
+					}
+				}
+			}
+
+			awards := room.AwardRecords()
+			for i, player := range room.players {
+				want := containsName(names(room, tt.holders), player.Name)
+				if got := room.HasBadge(player.Name, "speed-of-light"); got != want {
+					t.Errorf("player %d holds the record: %v, want %v", i, got, want)
+				}
+			}
+			if len(awards) != len(tt.holders) {
+				t.Errorf("%d players notified, want %d", len(awards), len(tt.holders))
+			}
+
+			// Badges are only awarded once
+			if again := room.AwardRecords(); len(again) != 0 {
+				t.Errorf("the record was awarded again to %d players", len(again))
+			}
+		})
+	}
+}
+
+func TestCheckAchievements(t *testing.T) {
+	tests := []struct {
+		name    string
+		correct int
+		want    []string
+	}{
+		{"nothing answered", 0, nil},
+		{"first answer", 1, []string{"first-contact"}},
+		{"streak", 10, []string{"first-contact", "on-a-roll"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, nil)
+			player := room.players[0]
+			created := time.Now()
+			for i := 0; i < tt.correct; i++ {
+				challenge := Challenge{ID: i, SourceIP: room.ip(player).String(), DestIP: room.ip(room.players[1]).String()}
+				room.record(challenge, ChallengeResult{Created: created.Add(time.Duration(i)), Answered: created.Add(time.Second), Correct: true})
+			}
+
+			awards := room.CheckAchievements(player.Name)
+			var got []string
+			for _, badge := range awards[player] {
+				got = append(got, badge.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("awarded %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("awarded %v, want %v", got, tt.want)
+				}
+			}
+
+			if again := room.CheckAchievements(player.Name); len(again) != 0 {
+				t.Errorf("awarded %v again", again[player])
+			}
+		})
+	}
+}
+
+// names returns the names of the players with the given indices
+func names(room *testRoom, indices []int) []Name {
+	var names []Name
+	for _, i := range indices {
+		names = append(names, room.players[i].Name)
+	}
+	return names
+}
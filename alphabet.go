@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// An Alphabet is a set of symbols that Q/A table entries are built from.
+//
+// Every alphabet is curated so that no two of its symbols look alike (no Greek
+// capitals that double as Latin letters, no outlined vs filled shapes, ...)
+type Alphabet struct {
+	// The symbols of the alphabet
+	Symbols []string
+
+	// The number of symbols in every question and answer
+	Length int
+
+	// The string placed between symbols
+	Separator string
+}
+
+// Alphabets is every alphabet a room can choose from
+var Alphabets = map[string]Alphabet{
+	"hex": {
+		Symbols: strings.Split("0123456789ABCDEF", ""),
+		Length:  4,
+	},
+	"emoji": {
+		Symbols: []string{
+			"🍎", "🍌", "🍇", "🍉", "🍒", "🍍", "🥕", "🌽",
+			"🐶", "🐱", "🐸", "🐵", "🐧", "🐙", "🦀", "🐝",
+			"⚽", "🏀", "🎸", "🎲", "🚀", "🚲", "⏰", "🔑",
+			"🌵", "🌻", "🍄", "🌙", "🔥", "💧", "🎈", "🎁",
+		},
+		Length: 1,
+	},
+	"shapes": {
+		Symbols: []string{"●", "■", "▲", "◆", "★", "✚", "✖", "⬟", "♥", "☾", "⚑"},
+		Length:  3,
+	},
+	"greek": {
+		// Only the capitals that can't be mistaken for Latin letters
+		Symbols: []string{"Γ", "Δ", "Θ", "Λ", "Ξ", "Π", "Σ", "Φ", "Ψ", "Ω"},
+		Length:  3,
+	},
+	"suits": {
+		Symbols: []string{"♠", "♥", "♦", "♣"},
+		Length:  4,
+	},
+	"words": {
+		Symbols: []string{
+			"apple", "brick", "cloud", "drum", "ember", "fern", "glove", "harp",
+			"igloo", "jelly", "kite", "lemon", "maple", "nut", "otter", "piano",
+			"quilt", "robot", "sock", "tulip", "umbrella", "violin", "wagon", "yarn",
+			"zipper", "anchor", "button", "candle", "dragon", "engine", "feather", "garden",
+		},
+		Length:    1,
+		Separator: "-",
+	},
+}
+
+// DefaultAlphabet is the alphabet used by newly created rooms
+const DefaultAlphabet = "hex"
+
+// Random returns a random entry made of Length symbols
+func (alphabet Alphabet) Random() string {
+	return alphabet.RandomN(alphabet.Length)
+}
+
+// RandomN returns a random entry made of n symbols
+func (alphabet Alphabet) RandomN(n int) string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = alphabet.Symbols[rand.Intn(len(alphabet.Symbols))]
+	}
+	return strings.Join(symbols, alphabet.Separator)
+}
+
+// Split breaks an entry back into its symbols
+func (alphabet Alphabet) Split(entry string) []string {
+	if alphabet.Separator != "" {
+		return strings.Split(entry, alphabet.Separator)
+	}
+
+	// Symbols may be several bytes (or even several runes) long
+	var symbols []string
+	for entry != "" {
+		matched := false
+		for _, symbol := range alphabet.Symbols {
+			if strings.HasPrefix(entry, symbol) {
+				symbols = append(symbols, symbol)
+				entry = entry[len(symbol):]
+				matched = true
+				break
+			}
+		}
+
+		// Not made of this alphabet's symbols
+		if !matched {
+			return append(symbols, entry)
+		}
+	}
+	return symbols
+}
+
+// Distinguishable returns true if a and b can't be confused for one another.
+//
+// Entries of different lengths are always distinguishable. Otherwise single-symbol
+// alphabets only need entries to differ, while longer entries must differ in at
+// least two positions, so misreading a single symbol never produces another entry.
+func (alphabet Alphabet) Distinguishable(a, b string) bool {
+	as, bs := alphabet.Split(a), alphabet.Split(b)
+	if len(as) != len(bs) {
+		return true
+	}
+	if alphabet.Length == 1 {
+		return a != b
+	}
+
+	differences := 0
+	for i := range as {
+		if i >= len(bs) || as[i] != bs[i] {
+			differences++
+		}
+	}
+	return differences >= 2
+}
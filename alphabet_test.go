@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlphabetSymbols(t *testing.T) {
+	for name, alphabet := range Alphabets {
+		t.Run(name, func(t *testing.T) {
+			for i, a := range alphabet.Symbols {
+				for j, b := range alphabet.Symbols {
+					if i == j {
+						continue
+					}
+					if a == b {
+						t.Errorf("%q appears twice", a)
+					}
+
+					// Without a separator, a symbol that starts another makes Split ambiguous
+					if alphabet.Separator == "" && strings.HasPrefix(b, a) {
+						t.Errorf("%q is a prefix of %q", a, b)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAlphabetSplit(t *testing.T) {
+	for name, alphabet := range Alphabets {
+		for n := 1; n <= 3; n++ {
+			entry := alphabet.RandomN(n)
+			if got := alphabet.Split(entry); len(got) != n || strings.Join(got, alphabet.Separator) != entry {
+				t.Errorf("%s: Split(%q) = %q, want %d symbols", name, entry, got, n)
+			}
+		}
+	}
+
+	tests := []struct {
+		alphabet string
+		entry    string
+		want     []string
+	}{
+		{"hex", "0B1F", []string{"0", "B", "1", "F"}},
+		{"words", "kite-cloud", []string{"kite", "cloud"}},
+		{"shapes", "●■▲", []string{"●", "■", "▲"}},
+		{"shapes", "●x", []string{"●", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alphabet+" "+tt.entry, func(t *testing.T) {
+			got := Alphabets[tt.alphabet].Split(tt.entry)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Split(%q) = %q, want %q", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistinguishable(t *testing.T) {
+	tests := []struct {
+		alphabet string
+		a, b     string
+		want     bool
+	}{
+		{"hex", "0B1F", "0B1F", false},
+		{"hex", "0B1F", "0B1E", false},
+		{"hex", "0B1F", "0C1E", true},
+		{"hex", "0B1F", "0B1F0", true},
+		{"emoji", "🍎", "🍌", true},
+		{"emoji", "🍎", "🍎", false},
+		{"words", "kite", "cloud", true},
+		{"words", "kite-cloud", "kite-drum", true},
+		{"words", "kite-cloud", "kite-cloud", false},
+		{"suits", "♠♥♦♣", "♠♥♦♠", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alphabet+" "+tt.a+" "+tt.b, func(t *testing.T) {
+			if got := Alphabets[tt.alphabet].Distinguishable(tt.a, tt.b); got != tt.want {
+				t.Errorf("Distinguishable(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// The directory question banks are stored in
+const bankDir = "banks"
+
+var ErrBankNotFound = errors.New("question bank not found")
+
+// Bank names are used as file names, so they're kept simple
+var bankNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// BankEntry is a single instructor-authored question and its answer
+type BankEntry struct {
+	Question string `json:"question" yaml:"question"`
+	Answer   string `json:"answer" yaml:"answer"`
+}
+
+// QuestionBank is an instructor-authored set of questions that is distributed across the players' Q/A tables
+type QuestionBank struct {
+	// The bank's name (also its file name)
+	Name string `json:"name" yaml:"name"`
+
+	// The questions and answers
+	Entries []BankEntry `json:"entries" yaml:"entries"`
+}
+
+// ParseQuestionBank parses an uploaded bank in "csv", "json" or "yaml" format
+//
+// CSV files have a question and an answer column, with an optional header row.
+// JSON and YAML files are either a list of entries or a question -> answer map.
+func ParseQuestionBank(name, format string, data []byte) (QuestionBank, error) {
+	bank := QuestionBank{Name: name}
+
+	switch format {
+	case "csv":
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return bank, fmt.Errorf("invalid csv: %w", err)
+		}
+		for i, record := range records {
+			if len(record) != 2 {
+				return bank, fmt.Errorf("line %d: expected 2 columns (question, answer) got %d", i+1, len(record))
+			}
+			if i == 0 && strings.EqualFold(record[0], "question") && strings.EqualFold(record[1], "answer") {
+				continue
+			}
+			bank.Entries = append(bank.Entries, BankEntry{record[0], record[1]})
+		}
+	case "json", "yaml":
+		unmarshal := json.Unmarshal
+		if format == "yaml" {
+			unmarshal = yaml.Unmarshal
+		}
+
+		// Either a list of entries...
+		if err := unmarshal(data, &bank.Entries); err != nil {
+			// ...or a map of question -> answer
+			var table map[string]string
+			if err := unmarshal(data, &table); err != nil {
+				return bank, fmt.Errorf("invalid %s: expected a list of {question, answer} or a map of question to answer", format)
+			}
+			for question, answer := range table {
+				bank.Entries = append(bank.Entries, BankEntry{question, answer})
+			}
+			sort.Slice(bank.Entries, func(i, j int) bool {
+				return bank.Entries[i].Question < bank.Entries[j].Question
+			})
+		}
+	default:
+		return bank, fmt.Errorf("unknown format %q. Expected csv, json or yaml", format)
+	}
+
+	return bank, bank.Validate()
+}
+
+// Validate checks the bank's name, and that every question is present, answered and unique
+func (bank QuestionBank) Validate() error {
+	if !bankNamePattern.MatchString(bank.Name) {
+		return fmt.Errorf("invalid bank name %q. Use letters, numbers, - and _", bank.Name)
+	}
+	if len(bank.Entries) == 0 {
+		return fmt.Errorf("bank %q has no questions", bank.Name)
+	}
+
+	seen := make(map[string]int)
+	for i, entry := range bank.Entries {
+		question := strings.ToLower(strings.TrimSpace(entry.Question))
+		if question == "" || strings.TrimSpace(entry.Answer) == "" {
+			return fmt.Errorf("entry %d: questions and answers can't be empty", i+1)
+		}
+		if j, ok := seen[question]; ok {
+			return fmt.Errorf("entry %d: duplicate of entry %d (%q)", i+1, j+1, entry.Question)
+		}
+		seen[question] = i
+	}
+	return nil
+}
+
+// ListQuestionBanks returns the names of every bank in the library
+func ListQuestionBanks() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(bankDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadQuestionBank reads a bank from the library
+func LoadQuestionBank(name string) (QuestionBank, error) {
+	var bank QuestionBank
+	if !bankNamePattern.MatchString(name) {
+		return bank, ErrBankNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(bankDir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return bank, ErrBankNotFound
+	} else if err != nil {
+		return bank, err
+	}
+
+	if err := json.Unmarshal(data, &bank); err != nil {
+		return bank, err
+	}
+	return bank, bank.Validate()
+}
+
+// SaveQuestionBank writes a bank to the library, replacing any bank with the same name
+func SaveQuestionBank(bank QuestionBank) error {
+	if err := bank.Validate(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(bankDir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(bank, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(bankDir, bank.Name+".json"), data, 0o644)
+}
+
+// BanksHandler lists the question banks in the library
+// /banks
+func BanksHandler(w http.ResponseWriter, r *http.Request) {
+	names, err := ListQuestionBanks()
+	if err != nil {
+		http.Error(w, "Failed to list question banks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(names)
+}
+
+// BankHandler reads (GET) or uploads (POST) a question bank
+// /banks/{name}?format=csv|json|yaml
+func BankHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if r.Method == http.MethodPost {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read question bank", http.StatusBadRequest)
+			return
+		}
+
+		bank, err := ParseQuestionBank(name, r.FormValue("format"), data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := SaveQuestionBank(bank); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	bank, err := LoadQuestionBank(name)
+	if errors.Is(err, ErrBankNotFound) {
+		http.Error(w, "Question bank not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bank)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseQuestionBank(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		want    []BankEntry
+		wantErr string
+	}{
+		{"csv", "csv", "TCP port?,80\nUDP port?,53\n", []BankEntry{{"TCP port?", "80"}, {"UDP port?", "53"}}, ""},
+		{"csv with header", "csv", "Question,Answer\nTCP port?,80\n", []BankEntry{{"TCP port?", "80"}}, ""},
+		{"csv with a missing column", "csv", "TCP port?\n", nil, "line 1"},
+		{"json list", "json", `[{"question": "a", "answer": "1"}]`, []BankEntry{{"a", "1"}}, ""},
+		{"json map", "json", `{"b": "2", "a": "1"}`, []BankEntry{{"a", "1"}, {"b", "2"}}, ""},
+		{"yaml list", "yaml", "- question: a\n  answer: \"1\"\n", []BankEntry{{"a", "1"}}, ""},
+		{"yaml map", "yaml", "a: \"1\"\n", []BankEntry{{"a", "1"}}, ""},
+		{"invalid json", "json", `"a"`, nil, "invalid json"},
+		{"unknown format", "xml", "", nil, "unknown format"},
+		{"empty answer", "csv", "a, \n", nil, "can't be empty"},
+		{"duplicate question", "csv", "a,1\n A ,2\n", nil, "duplicate"},
+		{"no questions", "json", `[]`, nil, "no questions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bank, err := ParseQuestionBank("test", tt.format, []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseQuestionBank() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuestionBank() = %v", err)
+			}
+			if len(bank.Entries) != len(tt.want) {
+				t.Fatalf("entries = %v, want %v", bank.Entries, tt.want)
+			}
+			for i := range tt.want {
+				if bank.Entries[i] != tt.want[i] {
+					t.Errorf("entry %d = %v, want %v", i, bank.Entries[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuestionBankName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"subnetting-101", true},
+		{"week_3", true},
+		{"", false},
+		{"../secrets", false},
+		{"a b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bank := QuestionBank{Name: tt.name, Entries: []BankEntry{{"a", "1"}}}
+			if err := bank.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestQuestionBankLibrary(t *testing.T) {
+	// The library lives in the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	bank := QuestionBank{Name: "ports", Entries: []BankEntry{{"HTTP", "80"}}}
+	if err := SaveQuestionBank(bank); err != nil {
+		t.Fatalf("SaveQuestionBank() = %v", err)
+	}
+
+	names, err := ListQuestionBanks()
+	if err != nil || len(names) != 1 || names[0] != "ports" {
+		t.Errorf("ListQuestionBanks() = %v, %v", names, err)
+	}
+	if loaded, err := LoadQuestionBank("ports"); err != nil || loaded.Entries[0] != bank.Entries[0] {
+		t.Errorf("LoadQuestionBank() = %v, %v", loaded, err)
+	}
+	if _, err := LoadQuestionBank("missing"); !errors.Is(err, ErrBankNotFound) {
+		t.Errorf("LoadQuestionBank(missing) = %v, want ErrBankNotFound", err)
+	}
+	if _, err := LoadQuestionBank("../ports"); !errors.Is(err, ErrBankNotFound) {
+		t.Errorf("LoadQuestionBank(../ports) = %v, want ErrBankNotFound", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Delivery decides how many recipients of a broadcast or multicast challenge must reply
+type Delivery string
+
+const (
+	// DeliverAny completes the challenge as soon as one recipient replies
+	DeliverAny Delivery = "any"
+	// DeliverAll completes the challenge once every recipient has replied
+	DeliverAll Delivery = "all"
+)
+
+// MulticastGroup is a host-defined group of players sharing a group address
+type MulticastGroup struct {
+	// The group address (224.0.0.0 - 239.255.255.255)
+	Address string `json:"address"`
+
+	// The members of the group
+	Members []IP `json:"members"`
+}
+
+// Validate checks that the group address is a multicast address
+func (group MulticastGroup) Validate() error {
+	var a, b, c, d int
+	if _, err := fmt.Sscanf(group.Address, "%d.%d.%d.%d", &a, &b, &c, &d); err != nil {
+		return fmt.Errorf("multicast address %q is not an IPv4 address", group.Address)
+	}
+	if a < 224 || a > 239 || b < 0 || b > 255 || c < 0 || c > 255 || d < 0 || d > 255 {
+		return fmt.Errorf("multicast address %q is not in 224.0.0.0/4", group.Address)
+	}
+	return nil
+}
+
+// ChooseGroup randomly decides if a challenge from source should be addressed to a group.
+//
+// Returns the group address and the hosts that will receive the packet, or an
+// empty address for a regular unicast challenge.
+func (room *Room) ChooseGroup(source IP) (string, []IP) {
+	roll := rand.Float64()
+
+	// Broadcasts never leave the sender's subnet
+	if roll < room.Options.BroadcastChance {
+		var hosts []IP
+		for host := range room.Metadata.Subnets[source.Subnet] {
+			hosts = append(hosts, IP{source.Subnet, host})
+		}
+
+		recipients := room.inScope(source, withoutIP(hosts, source))
+		if len(recipients) > 0 {
+			return BroadcastAddress(source.Subnet).String(), recipients
+		}
+		return "", nil
+	}
+	roll -= room.Options.BroadcastChance
+
+	if roll < room.Options.MulticastChance && len(room.Options.MulticastGroups) > 0 {
+		group := room.Options.MulticastGroups[rand.Intn(len(room.Options.MulticastGroups))]
+
+		// Only members that have actually joined the room receive the packet
+		var hosts []IP
+		for _, member := range group.Members {
+			if _, ok := room.Metadata.Subnets[member.Subnet][member.Host]; ok {
+				hosts = append(hosts, member)
+			}
+		}
+
+		recipients := room.inScope(source, withoutIP(hosts, source))
+		if len(recipients) > 0 {
+			return group.Address, recipients
+		}
+	}
+
+	return "", nil
+}
+
+// Delivered returns true if enough recipients have replied to complete the challenge
+func (result ChallengeResult) Delivered(delivery Delivery) bool {
+	if delivery == DeliverAll {
+		return len(result.Responders) == len(result.Recipients)
+	}
+	return len(result.Responders) > 0
+}
+
+// Returns true if ips contains ip
+func containsIP(ips []IP, ip IP) bool {
+	for _, other := range ips {
+		if other == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a copy of ips with every occurrence of ip removed
+func withoutIP(ips []IP, ip IP) []IP {
+	result := make([]IP, 0, len(ips))
+	for _, other := range ips {
+		if other != ip {
+			result = append(result, other)
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDelivered(t *testing.T) {
+	recipients := []IP{{1, 2}, {1, 3}}
+	tests := []struct {
+		name       string
+		delivery   Delivery
+		responders []IP
+		want       bool
+	}{
+		{"any, nobody replied", DeliverAny, nil, false},
+		{"any, one replied", DeliverAny, recipients[:1], true},
+		{"all, one replied", DeliverAll, recipients[:1], false},
+		{"all, everyone replied", DeliverAll, recipients, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ChallengeResult{Recipients: recipients, Responders: tt.responders}
+			if got := result.Delivered(tt.delivery); got != tt.want {
+				t.Errorf("Delivered(%q) = %v, want %v", tt.delivery, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupResponder(t *testing.T) {
+	// Players 0, 4 and 8 share subnet 1
+	room := newTestRoom(t, 9, func(opts *RoomOptions) {
+		opts.BroadcastChance = 1
+		opts.GroupDelivery = DeliverAll
+	})
+	sender, first, second, outsider := room.players[0], room.players[4], room.players[8], room.players[1]
+
+	challenge, result := room.request(t, sender)
+	if challenge.DestIP != BroadcastAddress(1).String() {
+		t.Fatalf("challenge was sent to %s, want the broadcast address", challenge.DestIP)
+	}
+
+	// Both members of the subnet received the question
+	result.Recipients = []IP{room.ip(first), room.ip(second)}
+	room.Challenges[challenge] = result
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr string
+		replies int
+	}{
+		{"no token", func() string { return "" }, "NOT_A_RECIPIENT", 0},
+		{"token of someone who didn't receive it", func() string { return room.Tokens[outsider.Name][0] }, "NOT_A_RECIPIENT", 0},
+		{"token of a recipient", func() string { return room.Tokens[first.Name][0] }, "", 1},
+		{"the same recipient again", func() string { return room.Tokens[first.Name][0] }, "DUPLICATE_REPLY", 1},
+		{"the other recipient", func() string { return room.Tokens[second.Name][0] }, "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room.Answer(sender, AnswerMessage{
+				Destination:    challenge.DestIP,
+				Question:       challenge.Question,
+				Answer:         challenge.Answer,
+				ResponderToken: tt.token(),
+			})
+
+			errs := room.failures(sender)
+			if tt.wantErr == "" && len(errs) > 0 {
+				t.Errorf("errors = %v", errs)
+			}
+			if tt.wantErr != "" && (len(errs) == 0 || !strings.HasPrefix(errs[0], tt.wantErr)) {
+				t.Errorf("errors = %v, want %s", errs, tt.wantErr)
+			}
+			if got := len(room.result(challenge).Responders); got != tt.replies {
+				t.Errorf("%d responders, want %d", got, tt.replies)
+			}
+		})
+	}
+
+	if !room.result(challenge).Correct {
+		t.Error("every recipient replied, but the challenge is not complete")
+	}
+}
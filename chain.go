@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// ChooseHops randomly decides if a challenge to dest should be relayed through a chain of hosts
+//
+// Returns every hop in order (starting with dest), or nil for a regular challenge
+func (room *Room) ChooseHops(source, dest IP) []IP {
+	if room.Options.ChainLength < 2 || rand.Float64() >= room.Options.ChainChance {
+		return nil
+	}
+
+	// Every other reachable host, in a random order
+	network := room.Metadata.Network
+	var others []IP
+	for _, ip := range room.Metadata.IPAddresses {
+		if ip != source && ip != dest && room.InScope(source, ip) && network.Reachable(source, ip) && network.Reachable(ip, dest) {
+			others = append(others, ip)
+		}
+	}
+	if len(others) < room.Options.ChainLength-1 {
+		return nil
+	}
+	rand.Shuffle(len(others), func(i, j int) {
+		others[i], others[j] = others[j], others[i]
+	})
+
+	return append([]IP{dest}, others[:room.Options.ChainLength-1]...)
+}
+
+// NewChain builds the questions of a chain challenge from source through hops.
+//
+// The last hop answers a question from its own table. Working backwards, every
+// other hop is given a new table entry mapping the question it receives to the
+// token it forwards as the next hop's question.
+//
+// Returns the challenge and the question each hop receives.
+func (room *Room) NewChain(source Name, sourceIP IP, hops []IP) (Challenge, []string, bool) {
+	last := hops[len(hops)-1]
+	final, _, ok := room.ChooseQuestion(source, sourceIP, last.String(), []IP{last})
+	if !ok {
+		return Challenge{}, nil, false
+	}
+
+	questions := make([]string, len(hops))
+	questions[len(hops)-1] = final.Question
+	for i := len(hops) - 2; i >= 0; i-- {
+		questions[i] = room.newSymbol()
+		room.QABank[questions[i]] = questions[i+1]
+		room.QATables[room.Metadata.Subnets[hops[i].Subnet][hops[i].Host]][questions[i]] = questions[i+1]
+	}
+
+	return Challenge{
+		DestIP:   hops[0].String(),
+		SourceIP: sourceIP.String(),
+		Question: questions[0],
+		Answer:   final.Answer,
+	}, questions, true
+}
+
+// Relay is called to handle a Relay message from an intermediate hop of a chain challenge
+func (room *Room) Relay(client *Client, msg RelayMessage) {
+	room.Lock()
+	defer room.Unlock()
+
+	if room.State.State != Running && room.State.State != Stopping {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Relays can only be accepted while the room is running or stopping (state: %d)", room.State.State)))
+		return
+	}
+
+	ip, ok := room.address(client)
+	if !ok {
+		return
+	}
+	for challenge, result := range room.Challenges {
+		if len(result.Hops) == 0 || !result.Outstanding() || challenge.SourceIP != msg.Source {
+			continue
+		}
+
+		// Only the hop the chain is currently waiting on can relay
+		hop := result.Progress
+		if hop >= len(result.Hops)-1 || result.Hops[hop] != ip || result.HopQuestions[hop] != msg.Question {
+			continue
+		}
+
+		next := result.HopQuestions[hop+1]
+		correct := NormalizeAnswer(msg.Token) == NormalizeAnswer(next)
+		if correct {
+			result.Progress++
+			room.record(challenge, result)
+		}
+
+		_ = client.Send(NewGradeMessage(result.Hops[hop+1].String(), msg.Question, correct, 0, correct))
+		return
+	}
+
+	_ = client.Send(NewError(fmt.Sprintf("NO_RELAY: You have nothing to relay for %q from %s", msg.Question, msg.Source)))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChooseHops(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		chance  float64
+		length  int
+		want    int
+	}{
+		{"chains disabled", 6, 0, 3, 0},
+		{"two hosts", 6, 1, 2, 2},
+		{"three hosts", 6, 1, 3, 3},
+		{"not enough players", 3, 1, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, tt.players, func(opts *RoomOptions) {
+				opts.ChainChance = tt.chance
+				opts.ChainLength = tt.length
+			})
+			source, dest := room.ip(room.players[0]), room.ip(room.players[1])
+
+			hops := room.ChooseHops(source, dest)
+			if len(hops) != tt.want {
+				t.Fatalf("ChooseHops() = %v, want %d hops", hops, tt.want)
+			}
+			seen := map[IP]bool{source: true}
+			for i, hop := range hops {
+				if seen[hop] {
+					t.Errorf("%s appears twice in %v", hop, hops)
+				}
+				seen[hop] = true
+				if i == 0 && hop != dest {
+					t.Errorf("chain starts at %s, want %s", hop, dest)
+				}
+			}
+		})
+	}
+}
+
+func TestChainRelay(t *testing.T) {
+	room := newTestRoom(t, 4, func(opts *RoomOptions) {
+		opts.ChainChance = 1
+		opts.ChainLength = 3
+	})
+	sender := room.players[0]
+	challenge, result := room.request(t, sender)
+	if len(result.Hops) != 3 {
+		t.Fatalf("hops = %v, want 3", result.Hops)
+	}
+
+	// Every hop but the last can look up the next hop's question
+	for i, hop := range result.Hops[:2] {
+		name := room.Metadata.Subnets[hop.Subnet][hop.Host]
+		if got := room.QATables[name][result.HopQuestions[i]]; got != result.HopQuestions[i+1] {
+			t.Errorf("hop %d maps %q to %q, want %q", i, result.HopQuestions[i], got, result.HopQuestions[i+1])
+		}
+	}
+	last := result.Hops[2]
+	if room.QATables[room.Metadata.Subnets[last.Subnet][last.Host]][result.HopQuestions[2]] != challenge.Answer {
+		t.Error("the last hop can't answer the final question")
+	}
+
+	answer := func() {
+		room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: challenge.Answer})
+	}
+	relay := func(hop int, token string) {
+		room.Relay(room.ClientByIP(result.Hops[hop]), RelayMessage{Source: challenge.SourceIP, Question: result.HopQuestions[hop], Token: token})
+	}
+
+	steps := []struct {
+		name     string
+		step     func()
+		progress int
+		correct  bool
+		wantErr  string
+	}{
+		{"answer before any relay", answer, 0, false, "CHAIN_INCOMPLETE"},
+		{"second hop relays out of turn", func() { relay(1, result.HopQuestions[2]) }, 0, false, ""},
+		{"first hop relays the wrong token", func() { relay(0, "nope") }, 0, false, ""},
+		{"first hop relays", func() { relay(0, result.HopQuestions[1]) }, 1, false, ""},
+		{"answer before the second relay", answer, 1, false, "CHAIN_INCOMPLETE"},
+		{"second hop relays", func() { relay(1, result.HopQuestions[2]) }, 2, false, ""},
+		{"answer", answer, 2, true, ""},
+	}
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			s.step()
+			errs := room.failures(sender)
+			if s.wantErr != "" && (len(errs) == 0 || !strings.HasPrefix(errs[0], s.wantErr)) {
+				t.Errorf("errors = %v, want %s", errs, s.wantErr)
+			}
+			got := room.result(challenge)
+			if got.Progress != s.progress || got.Correct != s.correct {
+				t.Errorf("progress %d, correct %v; want %d, %v", got.Progress, got.Correct, s.progress, s.correct)
+			}
+		})
+	}
+
+	// Hops prove themselves by relaying, so they are all credited
+	if got := room.result(challenge).Credited(); len(got) != 3 {
+		t.Errorf("Credited() = %v, want every hop", got)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The ciphers a room can encrypt questions with
+const (
+	NoCipher  = ""
+	Caesar    = "caesar"
+	Vigenere  = "vigenere"
+	RSACipher = "rsa"
+)
+
+// CipherKey is a player's secret key. Questions sent to the player are encrypted with it
+//
+// Caesar and Vigenère shift letters within A-Z and digits within 0-9, anything
+// else passes through unchanged. RSA encrypts every character's code point
+// separately, producing space separated numbers.
+type CipherKey struct {
+	// The cipher this key belongs to
+	Cipher string `json:"cipher"`
+
+	// Caesar
+	Shift int `json:"shift,omitempty"`
+
+	// Vigenère
+	Keyword string `json:"keyword,omitempty"`
+
+	// RSA (n, e) is the public key, d is the private exponent
+	N int `json:"n,omitempty"`
+	E int `json:"e,omitempty"`
+	D int `json:"d,omitempty"`
+}
+
+// Primes used for RSA keys. Each is larger than the square root of the largest unicode
+// code point (0x10FFFF), so every product is too and any character can be encrypted
+var rsaPrimes = []int{
+	1061, 1063, 1069, 1087, 1091, 1093, 1097, 1103, 1109, 1117, 1123, 1129, 1151, 1153, 1163, 1171,
+	1181, 1187, 1193, 1201, 1213, 1217, 1223, 1229, 1231, 1237, 1249, 1259, 1277, 1279, 1283, 1289,
+}
+
+// NewCipherKey generates a random key for the cipher (nil for NoCipher)
+func NewCipherKey(cipher string) *CipherKey {
+	key := &CipherKey{Cipher: cipher}
+
+	switch cipher {
+	case Caesar:
+		key.Shift = 1 + rand.Intn(25)
+	case Vigenere:
+		keyword := make([]byte, 4+rand.Intn(3))
+		for i := range keyword {
+			keyword[i] = byte('A' + rand.Intn(26))
+		}
+		key.Keyword = string(keyword)
+	case RSACipher:
+		for {
+			p := rsaPrimes[rand.Intn(len(rsaPrimes))]
+			q := rsaPrimes[rand.Intn(len(rsaPrimes))]
+			if p == q {
+				continue
+			}
+
+			phi := (p - 1) * (q - 1)
+			key.N = p * q
+			key.E = 17
+			if d, ok := modInverse(key.E, phi); ok {
+				key.D = d
+				break
+			}
+		}
+	default:
+		return nil
+	}
+
+	return key
+}
+
+// Encrypt encrypts the plaintext with the key
+func (key *CipherKey) Encrypt(plaintext string) string {
+	switch key.Cipher {
+	case Caesar:
+		return shiftText(plaintext, func(int) int { return key.Shift })
+	case Vigenere:
+		return shiftText(plaintext, func(i int) int { return int(key.Keyword[i%len(key.Keyword)] - 'A') })
+	case RSACipher:
+		blocks := make([]string, 0, len(plaintext))
+		for _, r := range plaintext {
+			blocks = append(blocks, strconv.Itoa(modPow(int(r), key.E, key.N)))
+		}
+		return strings.Join(blocks, " ")
+	}
+	return plaintext
+}
+
+// Shifts every letter and digit of text, shift(i) is the shift of the i-th shifted character
+func shiftText(text string, shift func(i int) int) string {
+	var builder strings.Builder
+	i := 0
+	for _, r := range text {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			r = 'A' + (r-'A'+rune(shift(i)))%26
+			i++
+		case r >= 'a' && r <= 'z':
+			r = 'a' + (r-'a'+rune(shift(i)))%26
+			i++
+		case r >= '0' && r <= '9':
+			r = '0' + (r-'0'+rune(shift(i)))%10
+			i++
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// CanShift returns true if every symbol of the alphabet contains a letter or digit,
+// so a Caesar or Vigenère cipher actually changes it
+func (alphabet Alphabet) CanShift() bool {
+	for _, symbol := range alphabet.Symbols {
+		if strings.IndexFunc(symbol, func(r rune) bool { return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) }) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Computes base^exp mod m
+func modPow(base, exp, m int) int {
+	result := 1
+	base %= m
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			result = result * base % m
+		}
+		base = base * base % m
+	}
+	return result
+}
+
+// Computes the inverse of a mod m, if it exists
+func modInverse(a, m int) (int, bool) {
+	// Extended Euclidean algorithm
+	oldR, r := a, m
+	oldS, s := 1, 0
+	for r != 0 {
+		quotient := oldR / r
+		oldR, r = r, oldR-quotient*r
+		oldS, s = s, oldS-quotient*s
+	}
+	if oldR != 1 {
+		return 0, false
+	}
+	return ((oldS % m) + m) % m, true
+}
+
+// AssignKey generates a new secret key for a player
+func (room *Room) AssignKey(name Name) {
+	if key := NewCipherKey(room.Options.Cipher); key != nil {
+		room.Keys[name] = key
+	} else {
+		delete(room.Keys, name)
+	}
+}
+
+// validateCipher checks that the cipher can encrypt the room's questions
+func (opts RoomOptions) validateCipher() error {
+	switch opts.Cipher {
+	case NoCipher, RSACipher:
+		return nil
+	case Caesar, Vigenere:
+		// Generated and authored questions are written in English
+		if opts.QuestionBank != "" || len(opts.Generators) > 0 || Alphabets[opts.Alphabet].CanShift() {
+			return nil
+		}
+		return fmt.Errorf("the %s cipher can't encrypt the %q alphabet", opts.Cipher, opts.Alphabet)
+	}
+	return fmt.Errorf("unknown cipher %q", opts.Cipher)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// decrypt reverses Encrypt, the way the destination does by hand
+func decrypt(key *CipherKey, ciphertext string) string {
+	switch key.Cipher {
+	case Caesar:
+		return shiftText(ciphertext, func(int) int { return 26*10 - key.Shift })
+	case Vigenere:
+		return shiftText(ciphertext, func(i int) int { return 26*10 - int(key.Keyword[i%len(key.Keyword)]-'A') })
+	case RSACipher:
+		var builder strings.Builder
+		for _, block := range strings.Fields(ciphertext) {
+			c, _ := strconv.Atoi(block)
+			builder.WriteRune(rune(modPow(c, key.D, key.N)))
+		}
+		return builder.String()
+	}
+	return ciphertext
+}
+
+func TestRSAPrimes(t *testing.T) {
+	for i, p := range rsaPrimes {
+		for _, q := range rsaPrimes[i+1:] {
+			if p*q <= unicode.MaxRune {
+				t.Errorf("%d * %d = %d can't encrypt every code point", p, q, p*q)
+			}
+		}
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	plaintexts := []string{
+		"0B1F",
+		"Network address of 192.168.3.77/26",
+		"🍎🐶",
+		"ΓΔΘ",
+		string(rune(unicode.MaxRune)),
+	}
+
+	for _, cipher := range []string{Caesar, Vigenere, RSACipher} {
+		t.Run(cipher, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				key := NewCipherKey(cipher)
+				for _, plaintext := range plaintexts {
+					ciphertext := key.Encrypt(plaintext)
+					if got := decrypt(key, ciphertext); got != plaintext {
+						t.Fatalf("%+v: %q -> %q -> %q", key, plaintext, ciphertext, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestShiftText(t *testing.T) {
+	tests := []struct {
+		text  string
+		shift int
+		want  string
+	}{
+		{"ABC", 1, "BCD"},
+		{"xyz", 3, "abc"},
+		{"789", 5, "234"},
+		{"A-1 ★", 2, "C-3 ★"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := shiftText(tt.text, func(int) int { return tt.shift }); got != tt.want {
+				t.Errorf("shiftText(%q, %d) = %q, want %q", tt.text, tt.shift, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModInverse(t *testing.T) {
+	tests := []struct {
+		a, m int
+		want int
+		ok   bool
+	}{
+		{17, 3120, 2753, true},
+		{3, 11, 4, true},
+		{2, 4, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := modInverse(tt.a, tt.m)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("modInverse(%d, %d) = %d, %v, want %d, %v", tt.a, tt.m, got, ok, tt.want, tt.ok)
+		}
+	}
+}
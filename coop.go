@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The shortest time between two progress updates
+const progressInterval = time.Second
+
+// AddProgress counts a correct answer towards the class's progress
+//
+// In co-op mode reaching the goal stops the game, returning the message to broadcast.
+// The caller must hold the room's lock
+func (room *Room) AddProgress() *Message {
+	room.State.Progress++
+	room.queueProgress()
+
+	if room.State.Goal > 0 && room.State.Progress >= room.State.Goal && room.State.State == Running {
+		stop := room.Stop()
+		return &stop
+	}
+	return nil
+}
+
+// queueProgress schedules a progress update, sending at most one every progressInterval
+//
+// The caller must hold the room's lock
+func (room *Room) queueProgress() {
+	if room.progressPending {
+		return
+	}
+	room.progressPending = true
+
+	delay := time.Until(room.lastProgress.Add(progressInterval))
+	time.AfterFunc(max(delay, 0), room.flushProgress)
+}
+
+// flushProgress sends the latest progress to every client
+func (room *Room) flushProgress() {
+	room.Lock()
+	room.progressPending = false
+	room.lastProgress = time.Now()
+	msg := NewProgressMessage(room.State.Progress, room.State.Goal)
+	room.Unlock()
+
+	room.Broadcast(msg)
+}
+
+// StateHandler returns the room's public state, for the host's projector
+// /room/{code}/state
+func StateHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	room.RLock()
+	defer room.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(room.State)
+}
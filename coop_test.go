@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCoopGoal(t *testing.T) {
+	tests := []struct {
+		name    string
+		goal    int
+		correct int
+		wrong   int
+		want    RoomState
+	}{
+		{"no goal", 0, 3, 0, Running},
+		{"short of the goal", 3, 2, 0, Running},
+		{"wrong answers don't count", 2, 1, 3, Running},
+		{"goal reached", 2, 2, 0, Stopping},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.CoopGoal = tt.goal
+			})
+			sender := room.players[0]
+
+			for i := 0; i < tt.wrong; i++ {
+				challenge, _ := room.request(t, sender)
+				room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: "nope"})
+			}
+			for i := 0; i < tt.correct; i++ {
+				challenge, _ := room.request(t, sender)
+				room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: challenge.Answer})
+			}
+
+			room.RLock()
+			defer room.RUnlock()
+			if room.State.Progress != tt.correct {
+				t.Errorf("Progress = %d, want %d", room.State.Progress, tt.correct)
+			}
+			if room.State.State != tt.want {
+				t.Errorf("State = %d, want %d", room.State.State, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressIsThrottled(t *testing.T) {
+	room := newTestRoom(t, 1, func(opts *RoomOptions) {
+		opts.CoopGoal = 10
+	})
+	player := room.players[0]
+
+	progress := func() []ProgressMessage {
+		var updates []ProgressMessage
+		for _, payload := range room.received(player, Progress) {
+			var msg ProgressMessage
+			_ = json.Unmarshal(payload, &msg)
+			updates = append(updates, msg)
+		}
+		return updates
+	}
+
+	// Progress made in a burst is sent as a single update
+	room.Lock()
+	for i := 0; i < 3; i++ {
+		room.AddProgress()
+	}
+	room.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if updates := progress(); len(updates) != 1 || updates[0] != (ProgressMessage{Progress: 3, Goal: 10}) {
+		t.Fatalf("got %+v, want a single update with progress 3", updates)
+	}
+
+	// The next update waits until a second after the last one
+	room.Lock()
+	room.AddProgress()
+	room.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if updates := progress(); len(updates) != 0 {
+		t.Errorf("got %+v straight after the last update", updates)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// The policies a room can use to choose a challenge's destination
+const (
+	// Every other host is equally likely
+	RandomPolicy = "random"
+	// The host with the fewest outstanding incoming challenges
+	BalancedPolicy = "balanced"
+	// The least loaded host of the least loaded subnet
+	SubnetBalancedPolicy = "subnet-balanced"
+)
+
+// Outstanding returns the number of outstanding challenges sent by source
+func (room *Room) Outstanding(source IP) int {
+	count := 0
+	for challenge, result := range room.Challenges {
+		if challenge.SourceIP == source.String() && result.Outstanding() {
+			count++
+		}
+	}
+	return count
+}
+
+// Incoming returns the number of outstanding challenges waiting on each host
+func (room *Room) Incoming() map[IP]int {
+	load := make(map[IP]int)
+	for challenge, result := range room.Challenges {
+		if !result.Outstanding() {
+			continue
+		}
+
+		switch {
+		case len(result.Recipients) > 0:
+			for _, ip := range result.Recipients {
+				load[ip]++
+			}
+		case len(result.Hops) > 0:
+			load[result.Hops[result.Progress]]++
+		default:
+			if ip, err := ParseIP(challenge.DestIP); err == nil {
+				load[ip]++
+			}
+		}
+	}
+	return load
+}
+
+// ChooseDestination picks the destination of a unicast challenge from source using the room's policy
+//
+// Returns false if there is nobody source can send to
+func (room *Room) ChooseDestination(source IP) (IP, bool) {
+	nat := room.Metadata.NAT
+
+	// Every host but the source
+	var candidates []IP
+	for _, ip := range room.Metadata.IPAddresses {
+		if ip == source {
+			continue
+		}
+
+		// Hosts on the public side of a NAT can't reach the private subnets
+		if nat != nil && !nat.IsPrivate(source) && nat.IsPrivate(ip) {
+			continue
+		}
+
+		// Team mode may keep challenges inside (or outside) the sender's subnet
+		if !room.InScope(source, ip) {
+			continue
+		}
+
+		// Subnets that are down or partitioned can't be reached
+		if !room.Metadata.Network.Reachable(source, ip) {
+			continue
+		}
+
+		candidates = append(candidates, ip)
+	}
+
+	if len(candidates) == 0 {
+		return IP{}, false
+	}
+
+	// Fast players are sent across subnets
+	if room.Harder(source, CrossSubnetLevel) {
+		var remote []IP
+		for _, ip := range candidates {
+			if ip.Subnet != source.Subnet {
+				remote = append(remote, ip)
+			}
+		}
+		if len(remote) > 0 {
+			candidates = remote
+		}
+	}
+
+	// Shuffle so ties are broken randomly
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	switch room.Options.DestinationPolicy {
+	case BalancedPolicy:
+		return leastLoaded(candidates, room.Incoming()), true
+	case SubnetBalancedPolicy:
+		load := room.Incoming()
+
+		// Total the load of every subnet that has a candidate
+		subnets := make(map[int]int)
+		for _, ip := range candidates {
+			subnets[ip.Subnet] += load[ip]
+		}
+
+		// Average the load over each subnet's size, so large subnets aren't penalised
+		best := candidates[0].Subnet
+		for subnet, total := range subnets {
+			if total*len(room.Metadata.Subnets[best]) < subnets[best]*len(room.Metadata.Subnets[subnet]) {
+				best = subnet
+			}
+		}
+
+		var inSubnet []IP
+		for _, ip := range candidates {
+			if ip.Subnet == best {
+				inSubnet = append(inSubnet, ip)
+			}
+		}
+		return leastLoaded(inSubnet, load), true
+	}
+
+	return candidates[0], true
+}
+
+// Returns the first candidate with the smallest load
+func leastLoaded(candidates []IP, load map[IP]int) IP {
+	best := candidates[0]
+	for _, ip := range candidates[1:] {
+		if load[ip] < load[best] {
+			best = ip
+		}
+	}
+	return best
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxOutstanding(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		requests int
+		want     int
+		refused  bool
+	}{
+		{"no limit", 0, 3, 3, false},
+		{"under the limit", 3, 2, 2, false},
+		{"at the limit", 2, 2, 2, false},
+		{"over the limit", 1, 3, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 4, func(opts *RoomOptions) {
+				opts.MaxOutstanding = tt.limit
+			})
+			sender := room.players[0]
+
+			for i := 0; i < tt.requests; i++ {
+				room.RequestChallenge(sender, RequestChallengeMessage{})
+			}
+			if got := room.Outstanding(room.ip(sender)); got != tt.want {
+				t.Errorf("Outstanding = %d, want %d", got, tt.want)
+			}
+
+			refused := false
+			for _, err := range room.failures(sender) {
+				refused = refused || strings.HasPrefix(err, "TOO_MANY_CHALLENGES")
+			}
+			if refused != tt.refused {
+				t.Errorf("refused = %v, want %v", refused, tt.refused)
+			}
+		})
+	}
+}
+
+func TestChooseDestination(t *testing.T) {
+	// Player i is in subnet i%4+1, and everyone sends from player 0 (subnet 1).
+	// Subnet totals: 1 → 1 (player 4), 2 → 1, 3 → 2, 4 → 0
+	load := map[int]int{1: 1, 2: 1, 4: 1, 6: 1}
+
+	tests := []struct {
+		policy string
+		want   []int
+	}{
+		{BalancedPolicy, []int{3, 5, 7}},
+		{SubnetBalancedPolicy, []int{3, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			room := newTestRoom(t, 8, func(opts *RoomOptions) {
+				opts.DestinationPolicy = tt.policy
+			})
+			for player, n := range load {
+				for i := 0; i < n; i++ {
+					room.nextChallengeID++
+					room.Challenges[Challenge{
+						ID:       room.nextChallengeID,
+						DestIP:   room.ip(room.players[player]).String(),
+						SourceIP: room.ip(room.players[(player+1)%8]).String(),
+					}] = ChallengeResult{}
+				}
+			}
+
+			allowed := make(map[IP]bool)
+			for _, player := range tt.want {
+				allowed[room.ip(room.players[player])] = true
+			}
+
+			// Ties are broken randomly, so try a few times
+			for i := 0; i < 20; i++ {
+				ip, ok := room.ChooseDestination(room.ip(room.players[0]))
+				if !ok || !allowed[ip] {
+					t.Fatalf("ChooseDestination = %v, %v, want one of players %v", ip, ok, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIncoming(t *testing.T) {
+	room := newTestRoom(t, 4, nil)
+	a, b, c := room.ip(room.players[1]), room.ip(room.players[2]), room.ip(room.players[3])
+
+	tests := []struct {
+		name   string
+		result ChallengeResult
+		dest   IP
+		want   map[IP]int
+	}{
+		{"unicast", ChallengeResult{}, a, map[IP]int{a: 1}},
+		{"answered", ChallengeResult{Correct: true}, a, map[IP]int{}},
+		{"expired", ChallengeResult{Expired: true}, a, map[IP]int{}},
+		{"group", ChallengeResult{Recipients: []IP{a, b}}, c, map[IP]int{a: 1, b: 1}},
+		{"chain", ChallengeResult{Hops: []IP{a, b}, Progress: 1}, c, map[IP]int{b: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room.Challenges = map[Challenge]ChallengeResult{
+				{DestIP: tt.dest.String(), SourceIP: room.ip(room.players[0]).String()}: tt.result,
+			}
+			got := room.Incoming()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Incoming = %v, want %v", got, tt.want)
+			}
+			for ip, n := range tt.want {
+				if got[ip] != n {
+					t.Errorf("Incoming[%v] = %d, want %d", ip, got[ip], n)
+				}
+			}
+		})
+	}
+}
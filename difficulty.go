@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// The difficulty levels, each adding to the ones before it
+const (
+	// Challenges are sent to the sender's own subnet as often as any other
+	EasyLevel = iota
+	// Challenges are sent to other subnets whenever possible
+	CrossSubnetLevel
+	// Challenges use the longest questions available
+	LongQuestionLevel
+	// Challenges are encrypted, even if the room doesn't use a cipher
+	EncryptedLevel
+)
+
+// DifficultyRules decide how challenges adapt to each player
+type DifficultyRules struct {
+	// Challenges get harder as a player answers faster
+	Adaptive bool `json:"adaptive"`
+
+	// How far back (in seconds) a player's answer rate is measured
+	Window int `json:"window"`
+
+	// The answer rate (correct answers per minute) needed to reach each level above easy
+	Thresholds []float64 `json:"thresholds"`
+
+	// The score multiplier at each level, starting with easy (empty for no handicap)
+	Handicap []float64 `json:"handicap"`
+}
+
+// DefaultDifficultyRules returns the difficulty rules used by newly created rooms
+func DefaultDifficultyRules() DifficultyRules {
+	return DifficultyRules{
+		Window:     300,
+		Thresholds: []float64{1, 2, 4},
+		Handicap:   []float64{},
+	}
+}
+
+// Validate checks that the rules make sense
+func (rules DifficultyRules) Validate() error {
+	if rules.Window <= 0 {
+		return fmt.Errorf("difficulty window must be positive (got %d)", rules.Window)
+	}
+	if len(rules.Thresholds) > EncryptedLevel {
+		return fmt.Errorf("there are only %d levels above easy (got %d thresholds)", EncryptedLevel, len(rules.Thresholds))
+	}
+	for i, threshold := range rules.Thresholds {
+		if threshold < 0 || (i > 0 && threshold < rules.Thresholds[i-1]) {
+			return fmt.Errorf("difficulty thresholds must be positive and increasing (got %v)", rules.Thresholds)
+		}
+	}
+	if len(rules.Handicap) > len(rules.Thresholds)+1 {
+		return fmt.Errorf("there are %d levels but %d handicaps", len(rules.Thresholds)+1, len(rules.Handicap))
+	}
+	for _, multiplier := range rules.Handicap {
+		if multiplier < 0 {
+			return fmt.Errorf("handicaps must not be negative (got %v)", rules.Handicap)
+		}
+	}
+	return nil
+}
+
+// Multiplier returns the score multiplier for a level
+func (rules DifficultyRules) Multiplier(level int) float64 {
+	if level >= len(rules.Handicap) {
+		return 1
+	}
+	return rules.Handicap[level]
+}
+
+// AnswerRate returns how many challenges source has answered correctly per minute, recently
+func (room *Room) AnswerRate(source IP) float64 {
+	window := time.Duration(room.Options.Difficulty.Window) * time.Second
+
+	// Early in the game the rate is measured since the start
+	if elapsed := time.Since(room.State.StartTime); !room.State.StartTime.IsZero() && elapsed < window {
+		window = max(elapsed, time.Minute)
+	}
+
+	answered := 0
+	for challenge, result := range room.Challenges {
+		if challenge.SourceIP == source.String() && result.Correct && time.Since(result.Answered) < window {
+			answered++
+		}
+	}
+	return float64(answered) / window.Minutes()
+}
+
+// Level returns the difficulty level source has earned with their answer rate
+func (room *Room) Level(source IP) int {
+	rate := room.AnswerRate(source)
+
+	level := EasyLevel
+	for _, threshold := range room.Options.Difficulty.Thresholds {
+		if rate < threshold {
+			break
+		}
+		level++
+	}
+	return level
+}
+
+// Harder returns true if challenges from source should be at least as hard as level
+func (room *Room) Harder(source IP, level int) bool {
+	return room.Options.Difficulty.Adaptive && room.Level(source) >= level
+}
+
+// longest returns the challenges with the longest question
+func longest(challenges []Challenge) []Challenge {
+	var best []Challenge
+	length := 0
+	for _, challenge := range challenges {
+		switch n := len([]rune(challenge.Question)); {
+		case n > length:
+			best, length = []Challenge{challenge}, n
+		case n == length:
+			best = append(best, challenge)
+		}
+	}
+	return best
+}
+
+// addLongQuestion gives target a question a symbol longer than the alphabet's entries,
+// unless it already holds one source hasn't asked. Random entries all have the same
+// length, so otherwise fast players would have no longer questions to be sent.
+//
+// Returns true if target's table was changed. The caller must hold the room's lock
+func (room *Room) addLongQuestion(source, target IP) bool {
+	// Instructor-authored and generated questions already vary in length
+	if room.questionBank != nil || len(room.Options.Generators) > 0 {
+		return false
+	}
+
+	name, ok := room.Metadata.Subnets[target.Subnet][target.Host]
+	if !ok {
+		return false
+	}
+	table := room.QATables[name]
+	alphabet := Alphabets[room.Options.Alphabet]
+
+	asked := room.asked(source, target.String())
+	for question := range table {
+		if len(alphabet.Split(question)) > alphabet.Length && !asked[question] {
+			return false
+		}
+	}
+
+	for attempt := 0; attempt < qaAttempts; attempt++ {
+		question := alphabet.RandomN(alphabet.Length + 1)
+		if room.QABank.distinguishable(alphabet, question) {
+			answer := alphabet.Random()
+			room.QABank[question] = answer
+			table[question] = answer
+			return true
+		}
+	}
+	return false
+}
+
+// handicapped applies the handicap of the level a challenge was created at to its points
+func (rules DifficultyRules) handicapped(points int, result ChallengeResult) int {
+	return int(math.Round(float64(points) * rules.Multiplier(result.Level)))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDifficultyRulesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   DifficultyRules
+		wantErr bool
+	}{
+		{"default", DefaultDifficultyRules(), false},
+		{"no window", DifficultyRules{Window: 0}, true},
+		{"too many thresholds", DifficultyRules{Window: 60, Thresholds: []float64{1, 2, 3, 4}}, true},
+		{"decreasing thresholds", DifficultyRules{Window: 60, Thresholds: []float64{2, 1}}, true},
+		{"a handicap for every level", DifficultyRules{Window: 60, Thresholds: []float64{1}, Handicap: []float64{1, 0.5}}, false},
+		{"too many handicaps", DifficultyRules{Window: 60, Thresholds: []float64{1}, Handicap: []float64{1, 0.5, 0.25}}, true},
+		{"negative handicap", DifficultyRules{Window: 60, Handicap: []float64{-1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		answered int
+		want     int
+	}{
+		{"nothing answered", 0, EasyLevel},
+		{"one a minute", 5, CrossSubnetLevel},
+		{"two a minute", 10, LongQuestionLevel},
+		{"four a minute", 20, EncryptedLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, nil)
+			source := room.ip(room.players[0])
+			for i := 0; i < tt.answered; i++ {
+				room.Challenges[Challenge{ID: i, SourceIP: source.String()}] = ChallengeResult{Correct: true, Answered: time.Now()}
+			}
+			if got := room.Level(source); got != tt.want {
+				t.Errorf("Level = %d, want %d (rate %v)", got, tt.want, room.AnswerRate(source))
+			}
+		})
+	}
+}
+
+func TestHandicapped(t *testing.T) {
+	rules := DifficultyRules{Handicap: []float64{1, 0.5}}
+	tests := []struct {
+		name   string
+		points int
+		level  int
+		want   int
+	}{
+		{"easy", 3, EasyLevel, 3},
+		{"handicapped level", 3, CrossSubnetLevel, 2},
+		{"level without a handicap", 3, LongQuestionLevel, 3},
+		{"penalties are handicapped too", -4, CrossSubnetLevel, -2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.handicapped(tt.points, ChallengeResult{Level: tt.level}); got != tt.want {
+				t.Errorf("handicapped = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongest(t *testing.T) {
+	tests := []struct {
+		name      string
+		questions []string
+		want      int
+	}{
+		{"single", []string{"AB"}, 1},
+		{"one longest", []string{"AB", "ABC", "A"}, 1},
+		{"ties", []string{"ABC", "AB", "XYZ"}, 2},
+		{"symbols are counted, not bytes", []string{"🍎🍌", "ABC"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var challenges []Challenge
+			for _, question := range tt.questions {
+				challenges = append(challenges, Challenge{Question: question})
+			}
+			if got := longest(challenges); len(got) != tt.want {
+				t.Errorf("longest = %v, want %d challenges", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongQuestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		adaptive bool
+		extra    int
+	}{
+		{"slow player", "hex", false, 0},
+		{"fast player", "hex", true, 1},
+		{"fast player, single symbol alphabet", "emoji", true, 1},
+		{"fast player, separated symbols", "words", true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.Alphabet = tt.alphabet
+				opts.Difficulty.Adaptive = tt.adaptive
+
+				// Everyone starts at the long question level
+				opts.Difficulty.Thresholds = []float64{0, 0, 100}
+			})
+			sender, dest := room.players[0], room.players[1]
+			alphabet := Alphabets[tt.alphabet]
+
+			for i := 0; i < 3; i++ {
+				challenge, _ := room.request(t, sender)
+				if got, want := len(alphabet.Split(challenge.Question)), alphabet.Length+tt.extra; got != want {
+					t.Errorf("question %q has %d symbols, want %d", challenge.Question, got, want)
+				}
+				if _, ok := room.QATables[dest.Name][challenge.Question]; !ok {
+					t.Errorf("the destination can't answer %q", challenge.Question)
+				}
+			}
+
+			// The destination is told about its new questions
+			if got := len(room.received(dest, Userdata)) > 0; got != (tt.extra > 0) {
+				t.Errorf("destination sent userdata: %v, want %v", got, tt.extra > 0)
+			}
+		})
+	}
+}
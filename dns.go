@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The top level domain every hostname lives under
+const dnsDomain = "classnet"
+
+// DNSRecord is an "A" record mapping a hostname to an IP address
+type DNSRecord struct {
+	// The fully qualified hostname (blue-tiger.classnet)
+	Hostname string `json:"hostname"`
+
+	// The address the hostname resolves to
+	IP IP `json:"ip"`
+
+	// How long (in seconds) the record may be cached
+	TTL int `json:"ttl"`
+}
+
+// Resolution is a record a player was given by the server's resolver, cached until its TTL runs out
+type Resolution struct {
+	DNSRecord
+
+	// When the record was resolved
+	Resolved time.Time `json:"resolved"`
+}
+
+// Fresh returns true if the cached record may still be used (a TTL of 0 never runs out)
+func (resolution Resolution) Fresh(now time.Time) bool {
+	return resolution.TTL == 0 || now.Sub(resolution.Resolved) < time.Duration(resolution.TTL)*time.Second
+}
+
+// Hostname returns the DNS name of a player
+func (room *Room) Hostname(name Name) string {
+	hostname := name.Color + "-" + name.Animal
+
+	// Optionally place the player in their subnet's zone
+	if room.Options.DNSZonePerSubnet {
+		if ip, ok := room.Metadata.IPAddresses[name]; ok {
+			hostname += fmt.Sprintf(".subnet%d", ip.Subnet)
+		}
+	}
+
+	return hostname + "." + dnsDomain
+}
+
+// Zone returns every record the room knows about, sorted by hostname
+func (room *Room) Zone() []DNSRecord {
+	zone := make([]DNSRecord, 0, len(room.Metadata.IPAddresses))
+	for name, ip := range room.Metadata.IPAddresses {
+		zone = append(zone, DNSRecord{
+			Hostname: room.Hostname(name),
+			IP:       ip,
+			TTL:      room.Options.DNSTTL,
+		})
+	}
+
+	sort.Slice(zone, func(i, j int) bool {
+		return zone[i].Hostname < zone[j].Hostname
+	})
+	return zone
+}
+
+// LookupHostname finds the record for a hostname (case insensitive, trailing dot optional)
+func (room *Room) LookupHostname(hostname string) (DNSRecord, bool) {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	for name, ip := range room.Metadata.IPAddresses {
+		if room.Hostname(name) == hostname {
+			return DNSRecord{
+				Hostname: hostname,
+				IP:       ip,
+				TTL:      room.Options.DNSTTL,
+			}, true
+		}
+	}
+	return DNSRecord{}, false
+}
+
+// IsResolver returns true if the client has been designated as the room's DNS resolver
+func (room *Room) IsResolver(client *Client) bool {
+	resolver := room.Options.DNSResolver
+	if resolver == nil {
+		return false
+	}
+	ip, ok := room.Metadata.IPAddresses[client.Name]
+	return ok && ip == *resolver
+}
+
+// Resolver returns the client acting as the room's DNS resolver (nil if the server resolves)
+func (room *Room) Resolver() *Client {
+	for _, client := range room.Clients {
+		if room.IsResolver(client) {
+			return client
+		}
+	}
+	return nil
+}
+
+// CheckResolved returns an error unless the player holds a fresh record for the destination
+//
+// Only the server's resolver can see queries, so nothing is checked when a student resolves.
+// The caller must hold the room's lock
+func (room *Room) CheckResolved(name Name, dest IP, now time.Time) error {
+	if !room.Options.DNS || room.Options.DNSResolver != nil {
+		return nil
+	}
+
+	hostname := room.Hostname(room.Metadata.Subnets[dest.Subnet][dest.Host])
+	resolution, ok := room.Resolutions[name][hostname]
+	switch {
+	case !ok:
+		return fmt.Errorf("NOT_RESOLVED: Resolve %s before sending to it", hostname)
+	case resolution.IP != dest:
+		return fmt.Errorf("STALE_RECORD: Your record for %s points at %s, resolve it again", hostname, resolution.IP)
+	case !resolution.Fresh(now):
+		return fmt.Errorf("STALE_RECORD: Your record for %s expired, resolve it again", hostname)
+	}
+	return nil
+}
+
+// Resolve is called to handle a Resolve message
+func (room *Room) Resolve(client *Client, msg ResolveMessage) {
+	room.Lock()
+	defer room.Unlock()
+
+	if !room.Options.DNS {
+		_ = client.Send(NewError("DNS_DISABLED: This room does not use hostnames"))
+		return
+	}
+
+	// When a student runs the resolver, everyone else has to ask them
+	if resolver := room.Options.DNSResolver; resolver != nil && !room.IsResolver(client) {
+		_ = client.Send(NewError(fmt.Sprintf("REFUSED: Send your query to the resolver at %s", resolver)))
+		return
+	}
+
+	record, ok := room.LookupHostname(msg.Hostname)
+	if !ok {
+		_ = client.Send(NewError(fmt.Sprintf("NXDOMAIN: %s does not exist", msg.Hostname)))
+		return
+	}
+
+	// The player may use the record until its TTL runs out
+	if room.Resolutions[client.Name] == nil {
+		room.Resolutions[client.Name] = make(map[string]Resolution)
+	}
+	room.Resolutions[client.Name][record.Hostname] = Resolution{record, time.Now()}
+
+	_ = client.Send(NewResolvedMessage(record))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolutionFresh(t *testing.T) {
+	resolved := time.Now()
+	tests := []struct {
+		name string
+		ttl  int
+		age  time.Duration
+		want bool
+	}{
+		{"just resolved", 60, 0, true},
+		{"almost expired", 60, 59 * time.Second, true},
+		{"expired", 60, 60 * time.Second, false},
+		{"never expires", 0, time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolution := Resolution{DNSRecord{TTL: tt.ttl}, resolved}
+			if got := resolution.Fresh(resolved.Add(tt.age)); got != tt.want {
+				t.Errorf("Fresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckResolved(t *testing.T) {
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.DNS = true
+		opts.DNSTTL = 30
+	})
+	sender, dest := room.players[0], room.players[1]
+	hostname := room.Hostname(dest.Name)
+
+	tests := []struct {
+		name    string
+		resolve bool
+		age     time.Duration
+		record  IP
+		wantErr string
+	}{
+		{"never resolved", false, 0, IP{}, "NOT_RESOLVED"},
+		{"fresh record", true, time.Second, room.ip(dest), ""},
+		{"expired record", true, time.Minute, room.ip(dest), "STALE_RECORD"},
+		{"record for an old address", true, time.Second, IP{4, 9}, "STALE_RECORD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delete(room.Resolutions, sender.Name)
+			now := time.Now()
+			if tt.resolve {
+				room.Resolve(sender, ResolveMessage{Hostname: hostname})
+				resolution := room.Resolutions[sender.Name][hostname]
+				resolution.IP = tt.record
+				resolution.Resolved = now.Add(-tt.age)
+				room.Resolutions[sender.Name][hostname] = resolution
+			}
+
+			err := room.CheckResolved(sender.Name, room.ip(dest), now)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("CheckResolved() = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Errorf("CheckResolved() = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolverZoneRefresh(t *testing.T) {
+	resolver := IP{1, 1}
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.DNS = true
+		opts.DNSResolver = &resolver
+	})
+	student := room.players[0]
+
+	// Someone moves, changing their record
+	room.State.State = Waiting
+	room.JoinSubnet(room.players[1], JoinSubnetMessage{Subnet: 3})
+
+	updates := room.received(student, Userdata)
+	if len(updates) == 0 {
+		t.Fatal("the resolver was not sent its new zone")
+	}
+	var userdata RoomUserData
+	if err := json.Unmarshal(updates[len(updates)-1], &userdata); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range userdata.Zone {
+		if record.Hostname == room.Hostname(room.players[1].Name) && record.IP != room.ip(room.players[1]) {
+			t.Errorf("zone has %s at %s, want %s", record.Hostname, record.IP, room.ip(room.players[1]))
+		}
+	}
+	if len(userdata.Zone) != 2 {
+		t.Errorf("zone has %d records, want 2", len(userdata.Zone))
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The events a host can inject into a running game
+const (
+	// Every host in a subnet becomes unreachable
+	SubnetDownEvent = "subnet-down"
+	// A subnet that was down comes back
+	SubnetUpEvent = "subnet-up"
+	// Two subnets can no longer reach each other
+	PartitionEvent = "partition"
+	// A partition between two subnets is repaired
+	HealEvent = "heal"
+	// A player is moved to another subnet, and given a new address
+	MoveEvent = "move"
+	// Every Q/A table is regenerated
+	RotateEvent = "rotate"
+)
+
+var ErrNotRunning = errors.New("events can only be triggered while the game is running")
+
+// NetworkState is the health of the room's network
+type NetworkState struct {
+	// The subnets that are down
+	Down []int `json:"down"`
+
+	// The pairs of subnets that can't reach each other (smallest subnet first)
+	Partitions [][2]int `json:"partitions"`
+}
+
+// IsDown returns true if the subnet is down
+func (network NetworkState) IsDown(subnet int) bool {
+	for _, down := range network.Down {
+		if down == subnet {
+			return true
+		}
+	}
+	return false
+}
+
+// Partitioned returns true if subnets a and b have been partitioned
+func (network NetworkState) Partitioned(a, b int) bool {
+	pair := partition(a, b)
+	for _, p := range network.Partitions {
+		if p == pair {
+			return true
+		}
+	}
+	return false
+}
+
+// Reachable returns true if a packet can get from a to b
+func (network NetworkState) Reachable(a, b IP) bool {
+	return !network.IsDown(a.Subnet) && !network.IsDown(b.Subnet) && !network.Partitioned(a.Subnet, b.Subnet)
+}
+
+// Returns the pair of subnets in a consistent order
+func partition(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// NetworkEvent is an event the host injected into the game
+type NetworkEvent struct {
+	// The kind of event (see SubnetDownEvent, etc.)
+	Kind string `json:"kind"`
+
+	// The subnet taken down, brought up, partitioned or moved to
+	Subnet int `json:"subnet,omitempty"`
+
+	// The other side of a partition
+	Peer int `json:"peer,omitempty"`
+
+	// The player that was moved
+	Player string `json:"player,omitempty"`
+
+	// When the event happened
+	Time time.Time `json:"time"`
+}
+
+// Validate checks that the event makes sense for a room with numSubnets subnets
+func (event NetworkEvent) Validate(numSubnets int) error {
+	validSubnet := func(subnet int) bool {
+		return subnet > 0 && subnet <= numSubnets
+	}
+
+	switch event.Kind {
+	case SubnetDownEvent, SubnetUpEvent, MoveEvent:
+		if !validSubnet(event.Subnet) {
+			return fmt.Errorf("subnet %d does not exist. Expected 1 <= subnet <= %d", event.Subnet, numSubnets)
+		}
+	case PartitionEvent, HealEvent:
+		if !validSubnet(event.Subnet) || !validSubnet(event.Peer) || event.Subnet == event.Peer {
+			return fmt.Errorf("can't partition subnets %d and %d", event.Subnet, event.Peer)
+		}
+	case RotateEvent:
+	default:
+		return fmt.Errorf("unknown event %q", event.Kind)
+	}
+	return nil
+}
+
+// expireBroken expires every outstanding challenge that involves a host matching the filter
+//
+// The caller must hold the room's lock
+func (room *Room) expireBroken(broken func(source, target IP) bool) []expiredChallenge {
+	return room.expireMatching(func(challenge Challenge, result ChallengeResult) bool {
+		source, err := ParseIP(challenge.SourceIP)
+		if err != nil {
+			return false
+		}
+
+		targets := result.Recipients
+		switch {
+		case len(result.Hops) > 0:
+			targets = result.Hops
+		case len(targets) == 0:
+			dest, err := ParseIP(challenge.DestIP)
+			if err != nil {
+				return false
+			}
+			targets = []IP{dest}
+		}
+
+		for _, target := range targets {
+			if broken(source, target) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// TriggerEvent applies a network event to the running game and tells every client about it
+func (room *Room) TriggerEvent(event NetworkEvent) error {
+	room.Lock()
+	if room.State.State != Running {
+		room.Unlock()
+		return ErrNotRunning
+	}
+	if err := event.Validate(room.Metadata.NumSubnets); err != nil {
+		room.Unlock()
+		return err
+	}
+
+	network := &room.Metadata.Network
+	var expired []expiredChallenge
+	var moved *Client
+	var assigned IP
+	switch event.Kind {
+	case SubnetDownEvent:
+		if !network.IsDown(event.Subnet) {
+			network.Down = append(network.Down, event.Subnet)
+		}
+		expired = room.expireBroken(func(source, target IP) bool {
+			return !network.Reachable(source, target)
+		})
+	case SubnetUpEvent:
+		for i, down := range network.Down {
+			if down == event.Subnet {
+				network.Down = append(network.Down[:i], network.Down[i+1:]...)
+				break
+			}
+		}
+	case PartitionEvent:
+		if !network.Partitioned(event.Subnet, event.Peer) {
+			network.Partitions = append(network.Partitions, partition(event.Subnet, event.Peer))
+		}
+		expired = room.expireBroken(func(source, target IP) bool {
+			return !network.Reachable(source, target)
+		})
+	case HealEvent:
+		pair := partition(event.Subnet, event.Peer)
+		for i, p := range network.Partitions {
+			if p == pair {
+				network.Partitions = append(network.Partitions[:i], network.Partitions[i+1:]...)
+				break
+			}
+		}
+	case MoveEvent:
+		for _, client := range room.Clients {
+			if client.Name.String() == event.Player {
+				moved = client
+				break
+			}
+		}
+		if moved == nil {
+			room.Unlock()
+			return ErrClientNotFound
+		}
+
+		var err error
+		if assigned, expired, err = room.movePlayer(moved.Name, event.Subnet); err != nil {
+			room.Unlock()
+			return err
+		}
+	case RotateEvent:
+		room.RegenerateQATables()
+		expired = room.expireMatching(func(Challenge, ChallengeResult) bool {
+			return true
+		})
+	}
+
+	event.Time = time.Now()
+	room.State.Events = append(room.State.Events, event)
+	room.UpdateScoreboard()
+	room.Unlock()
+
+	room.Broadcast(NewEventMessage(event))
+	if moved != nil {
+		_ = moved.Send(NewAssignedIPMessage(assigned))
+	}
+	notifyExpired(expired)
+
+	room.BroadcastMetadata()
+	room.BroadcastGameState()
+	switch event.Kind {
+	case RotateEvent:
+		room.BroadcastUserdata()
+	case MoveEvent:
+		room.SendUserdata(moved)
+	}
+	return nil
+}
+
+// EventsHandler lets the host trigger a network event (POST), and returns the room's event history
+// /room/{code}/events?key=<key>
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !authorizeHost(w, r, room) {
+			return
+		}
+
+		var event NetworkEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, "Failed to decode event", http.StatusBadRequest)
+			return
+		}
+
+		if err := room.TriggerEvent(event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	room.RLock()
+	defer room.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(room.State.Events)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestReachable(t *testing.T) {
+	network := NetworkState{Down: []int{3}, Partitions: [][2]int{{1, 2}}}
+	tests := []struct {
+		name string
+		a, b IP
+		want bool
+	}{
+		{"same subnet", IP{1, 1}, IP{1, 2}, true},
+		{"healthy subnets", IP{1, 1}, IP{4, 1}, true},
+		{"partitioned", IP{1, 1}, IP{2, 1}, false},
+		{"partitioned, other way round", IP{2, 1}, IP{1, 1}, false},
+		{"to a subnet that is down", IP{1, 1}, IP{3, 1}, false},
+		{"within a subnet that is down", IP{3, 1}, IP{3, 2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := network.Reachable(tt.a, tt.b); got != tt.want {
+				t.Errorf("Reachable(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkEventValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   NetworkEvent
+		wantErr bool
+	}{
+		{"subnet down", NetworkEvent{Kind: SubnetDownEvent, Subnet: 2}, false},
+		{"missing subnet", NetworkEvent{Kind: SubnetDownEvent, Subnet: 5}, true},
+		{"partition", NetworkEvent{Kind: PartitionEvent, Subnet: 1, Peer: 2}, false},
+		{"partition with itself", NetworkEvent{Kind: PartitionEvent, Subnet: 1, Peer: 1}, true},
+		{"move without a subnet", NetworkEvent{Kind: MoveEvent}, true},
+		{"rotate", NetworkEvent{Kind: RotateEvent}, false},
+		{"unknown", NetworkEvent{Kind: "flood"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.event.Validate(4); (err != nil) != tt.wantErr {
+				t.Errorf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTriggerEvent(t *testing.T) {
+	// Player 0 (subnet 1) sends to player 1 (subnet 2)
+	tests := []struct {
+		name    string
+		events  []NetworkEvent
+		expired bool
+	}{
+		{"destination's subnet down", []NetworkEvent{{Kind: SubnetDownEvent, Subnet: 2}}, true},
+		{"unrelated subnet down", []NetworkEvent{{Kind: SubnetDownEvent, Subnet: 3}}, false},
+		{"partition", []NetworkEvent{{Kind: PartitionEvent, Subnet: 2, Peer: 1}}, true},
+		{"unrelated partition", []NetworkEvent{{Kind: PartitionEvent, Subnet: 3, Peer: 4}}, false},
+		{"rotate", []NetworkEvent{{Kind: RotateEvent}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, nil)
+			challenge, _ := room.request(t, room.players[0])
+
+			for _, event := range tt.events {
+				if err := room.TriggerEvent(event); err != nil {
+					t.Fatalf("TriggerEvent: %v", err)
+				}
+			}
+			if got := room.result(challenge).Expired; got != tt.expired {
+				t.Errorf("Expired = %v, want %v", got, tt.expired)
+			}
+			if len(room.State.Events) != len(tt.events) {
+				t.Errorf("%d events recorded, want %d", len(room.State.Events), len(tt.events))
+			}
+			if n := len(room.received(room.players[1], Event)); n != len(tt.events) {
+				t.Errorf("%d events broadcast, want %d", n, len(tt.events))
+			}
+		})
+	}
+}
+
+func TestTriggerEventNotRunning(t *testing.T) {
+	room := newTestRoom(t, 2, nil)
+	room.State.State = Stopped
+	if err := room.TriggerEvent(NetworkEvent{Kind: RotateEvent}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("TriggerEvent error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestEventsAreArchivedPerRound(t *testing.T) {
+	room := newTournament(t, 3)
+
+	for round, events := range [][]NetworkEvent{
+		{{Kind: SubnetDownEvent, Subnet: 2}, {Kind: SubnetUpEvent, Subnet: 2}},
+		{{Kind: RotateEvent}},
+	} {
+		for _, event := range events {
+			if err := room.TriggerEvent(event); err != nil {
+				t.Fatalf("TriggerEvent: %v", err)
+			}
+		}
+		room.finishRound()
+		if err := room.Restart(); err != nil {
+			t.Fatalf("Restart: %v", err)
+		}
+		room.State.State = Running
+
+		summary := room.State.Tournament.Summaries[round]
+		if len(summary.Events) != len(events) {
+			t.Errorf("round %d: %d events archived, want %d", round+1, len(summary.Events), len(events))
+		}
+		if len(room.State.Events) != 0 {
+			t.Errorf("round %d: the next round starts with %d events", round+1, len(room.State.Events))
+		}
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		host   bool
+		status int
+		events int
+	}{
+		{"anyone can read the history", http.MethodGet, false, http.StatusOK, 0},
+		{"host triggers an event", http.MethodPost, true, http.StatusOK, 1},
+		{"player triggers an event", http.MethodPost, false, http.StatusForbidden, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, nil)
+
+			w := room.serve(t, EventsHandler, tt.method, tt.host, "", `{"kind":"rotate"}`, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if len(room.State.Events) != tt.events {
+				t.Errorf("%d events, want %d", len(room.State.Events), tt.events)
+			}
+		})
+	}
+}
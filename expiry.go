@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// How often a room checks for expired challenges
+const expiryInterval = time.Second
+
+// Outstanding returns true if the challenge is still waiting to be answered
+func (result ChallengeResult) Outstanding() bool {
+	return !result.Correct && !result.Expired
+}
+
+// ExpireChallenges periodically expires the room's challenges, like packets that time out
+func (room *Room) ExpireChallenges() {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		room.expire(now)
+	}
+}
+
+// expiredChallenge is a challenge that just expired, and the sender to notify
+type expiredChallenge struct {
+	client    *Client
+	challenge Challenge
+	result    ChallengeResult
+}
+
+// expire marks every outstanding challenge older than the room's ChallengeTTL as expired
+// and notifies their senders
+func (room *Room) expire(now time.Time) {
+	room.Lock()
+	ttl := time.Duration(room.Options.ChallengeTTL) * time.Second
+	if ttl == 0 {
+		room.Unlock()
+		return
+	}
+
+	notifications := room.expireMatching(func(challenge Challenge, result ChallengeResult) bool {
+		return now.Sub(result.Created) >= ttl
+	})
+	translating := room.Metadata.NAT != nil
+	room.Unlock()
+
+	notifyExpired(notifications)
+
+	// Expired challenges lose their translations
+	if translating && len(notifications) > 0 {
+		room.BroadcastMetadata()
+	}
+}
+
+// expireMatching marks every outstanding challenge matching the filter as expired,
+// returning the senders to notify. The gateway forgets their translations
+//
+// The caller must hold the room's lock
+func (room *Room) expireMatching(match func(Challenge, ChallengeResult) bool) []expiredChallenge {
+	var notifications []expiredChallenge
+	for challenge, result := range room.Challenges {
+		if !result.Outstanding() || !match(challenge, result) {
+			continue
+		}
+
+		result.Expired = true
+		room.record(challenge, result)
+		room.releaseMapping(result)
+
+		source, err := ParseIP(challenge.SourceIP)
+		if err != nil {
+			continue
+		}
+		if client := room.ClientByIP(source); client != nil {
+			notifications = append(notifications, expiredChallenge{client, challenge, result})
+		}
+	}
+	return notifications
+}
+
+// notifyExpired tells senders their challenges expired
+func notifyExpired(notifications []expiredChallenge) {
+	for _, n := range notifications {
+		log.Printf("Challenge from %s to %s expired\n", n.challenge.SourceIP, n.challenge.DestIP)
+		_ = n.client.Send(NewExpiredMessage(n.challenge.DestIP, n.result.Transmitted(n.challenge)))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     int
+		age     time.Duration
+		correct bool
+		want    bool
+	}{
+		{"young challenge", 30, 10 * time.Second, false, false},
+		{"old challenge", 30, 30 * time.Second, false, true},
+		{"answered challenge", 30, time.Minute, true, false},
+		{"challenges never expire", 0, time.Hour, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.ChallengeTTL = tt.ttl
+			})
+			challenge, result := room.request(t, room.players[0])
+			result.Correct = tt.correct
+			room.Challenges[challenge] = result
+
+			room.expire(result.Created.Add(tt.age))
+			if got := room.result(challenge).Expired; got != tt.want {
+				t.Errorf("Expired = %v, want %v", got, tt.want)
+			}
+			if got := len(room.received(room.players[0], Expired)) == 1; got != tt.want {
+				t.Errorf("sender told about the expiry: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredRecordsAreKept(t *testing.T) {
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.ChallengeTTL = 30
+		opts.Scoring.WrongPenalty = 1
+	})
+	sender, dest := room.players[0], room.players[1]
+
+	// The destination only knows a single question, so it is asked again once it expires
+	room.QATables[dest.Name] = QATable{"QQQQ": "AAAA"}
+	delete(room.QATables[sender.Name], "QQQQ")
+
+	first, result := room.request(t, sender)
+	room.Answer(sender, AnswerMessage{Destination: first.DestIP, Question: first.Question, Answer: "nope"})
+	room.expire(result.Created.Add(time.Minute))
+
+	second, _ := room.request(t, sender)
+	if second.Question != first.Question || second.ID == first.ID {
+		t.Fatalf("got %+v after %+v, want the same question with a new ID", second, first)
+	}
+
+	if old := room.result(first); !old.Expired || old.Wrong != 1 {
+		t.Errorf("expired record = %+v, want it expired with 1 wrong answer", old)
+	}
+	if got := room.Scores()[sender.Name]; got != -1 {
+		t.Errorf("score = %d, the expired challenge's penalty was lost", got)
+	}
+
+	// Answers go to the new challenge, not the expired one
+	room.Answer(sender, AnswerMessage{Destination: first.DestIP, Question: first.Question, Answer: "AAAA"})
+	if !room.result(second).Correct || room.result(first).Correct {
+		t.Errorf("the answer went to the wrong challenge")
+	}
+
+	// Asked and answered, so it isn't asked a third time
+	room.RequestChallenge(sender, RequestChallengeMessage{})
+	if got := len(room.Challenges); got != 2 {
+		t.Errorf("%d challenges, want 2", got)
+	}
+}
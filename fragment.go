@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// DatagramKey identifies a datagram by its source and the ID the server gave it
+type DatagramKey struct {
+	Source IP
+	ID     int
+}
+
+// Fragment is a single piece of a fragmented datagram
+type Fragment struct {
+	// The offset (in characters) of this fragment's data within the datagram
+	Offset int `json:"offset"`
+	// If more fragments follow this one
+	MoreFragments bool `json:"more_fragments"`
+	// The fragment's data
+	Data string `json:"data"`
+}
+
+// Datagram is a question that is too large for the path MTU and must be fragmented
+type Datagram struct {
+	// The datagram's ID (unique per room)
+	ID int `json:"id"`
+
+	// The two ends of the datagram
+	Source IP `json:"source"`
+	Dest   IP `json:"dest"`
+
+	// The smallest MTU along the path
+	MTU int `json:"mtu"`
+
+	// The fragments received so far, ordered by offset
+	Fragments []Fragment `json:"fragments"`
+
+	// If the destination has reassembled the datagram correctly
+	Reassembled bool `json:"reassembled"`
+
+	// The question being carried (never sent to clients)
+	payload string
+}
+
+// PathMTU returns the smallest MTU between two subnets (0 means unlimited)
+func (room *Room) PathMTU(a, b int) int {
+	mtu := 0
+	for _, subnet := range []int{a, b} {
+		if m := room.Options.MTU[subnet]; m > 0 && (mtu == 0 || m < mtu) {
+			mtu = m
+		}
+	}
+	return mtu
+}
+
+// NewDatagram registers a question that has to be fragmented to reach its destination
+//
+// Returns nil if the question fits within the path MTU
+func (room *Room) NewDatagram(source, dest IP, question string) *Datagram {
+	mtu := room.PathMTU(source.Subnet, dest.Subnet)
+	if mtu == 0 || utf8.RuneCountInString(question) <= mtu {
+		return nil
+	}
+
+	room.nextDatagramID++
+	datagram := &Datagram{
+		ID:        room.nextDatagramID,
+		Source:    source,
+		Dest:      dest,
+		MTU:       mtu,
+		Fragments: []Fragment{},
+		payload:   question,
+	}
+	room.Datagrams[DatagramKey{source, datagram.ID}] = datagram
+	return datagram
+}
+
+// Complete returns true once fragments cover the whole datagram without gaps
+func (datagram *Datagram) Complete() bool {
+	offset := 0
+	for _, fragment := range datagram.Fragments {
+		if fragment.Offset != offset {
+			return false
+		}
+		offset += utf8.RuneCountInString(fragment.Data)
+		if !fragment.MoreFragments {
+			return true
+		}
+	}
+	return false
+}
+
+// Data returns the data carried by the fragments, in order
+func (datagram *Datagram) Data() string {
+	data := ""
+	for _, fragment := range datagram.Fragments {
+		data += fragment.Data
+	}
+	return data
+}
+
+// addFragment validates a fragment's header and stores it
+func (datagram *Datagram) addFragment(fragment Fragment) error {
+	if datagram.Complete() {
+		return fmt.Errorf("DATAGRAM_COMPLETE: Datagram %d has already been fully sent", datagram.ID)
+	}
+	if fragment.Data == "" {
+		return fmt.Errorf("EMPTY_FRAGMENT: Fragments must carry data")
+	}
+	payload := []rune(datagram.payload)
+	length := utf8.RuneCountInString(fragment.Data)
+	if length > datagram.MTU {
+		return fmt.Errorf("FRAGMENT_TOO_LARGE: The path MTU is %d but the fragment carries %d characters", datagram.MTU, length)
+	}
+	if fragment.Offset < 0 || fragment.Offset+length > len(payload) {
+		return fmt.Errorf("BAD_OFFSET: Fragment at offset %d does not fit in the datagram", fragment.Offset)
+	}
+	end := fragment.Offset + length
+	if fragment.MoreFragments == (end == len(payload)) {
+		return fmt.Errorf("BAD_FLAGS: Only the last fragment may clear the more-fragments flag")
+	}
+
+	// Fragments may arrive in any order, but may never overlap
+	for _, other := range datagram.Fragments {
+		if fragment.Offset < other.Offset+utf8.RuneCountInString(other.Data) && other.Offset < end {
+			return fmt.Errorf("OVERLAPPING_FRAGMENT: Fragment at offset %d overlaps the fragment at offset %d", fragment.Offset, other.Offset)
+		}
+	}
+
+	if fragment.Data != string(payload[fragment.Offset:end]) {
+		return fmt.Errorf("CORRUPT_FRAGMENT: Fragment at offset %d does not match the question", fragment.Offset)
+	}
+
+	datagram.Fragments = append(datagram.Fragments, fragment)
+	sort.Slice(datagram.Fragments, func(i, j int) bool {
+		return datagram.Fragments[i].Offset < datagram.Fragments[j].Offset
+	})
+	return nil
+}
+
+// SendFragment is called to handle a Fragment message from the datagram's source
+func (room *Room) SendFragment(client *Client, msg FragmentMessage) {
+	room.Lock()
+	defer room.Unlock()
+
+	source := room.Metadata.IPAddresses[client.Name]
+	datagram, ok := room.Datagrams[DatagramKey{source, msg.ID}]
+	if !ok {
+		_ = client.Send(NewError(fmt.Sprintf("NO_DATAGRAM: You have no datagram with ID %d", msg.ID)))
+		return
+	}
+
+	if err := datagram.addFragment(msg.Fragment); err != nil {
+		_ = client.Send(NewError(err.Error()))
+		return
+	}
+
+	room.sendDatagramState(datagram)
+}
+
+// Reassemble is called to handle a Reassemble message from the datagram's destination
+func (room *Room) Reassemble(client *Client, msg ReassembleMessage) {
+	room.Lock()
+	defer room.Unlock()
+
+	source, err := ParseIP(msg.Source)
+	if err != nil {
+		_ = client.Send(NewError(fmt.Sprintf("INVALID_SOURCE: %v", err)))
+		return
+	}
+
+	datagram, ok := room.Datagrams[DatagramKey{source, msg.ID}]
+	if !ok || datagram.Dest != room.Metadata.IPAddresses[client.Name] {
+		_ = client.Send(NewError(fmt.Sprintf("NO_DATAGRAM: You were not sent datagram %d from %s", msg.ID, source)))
+		return
+	}
+
+	if !datagram.Complete() {
+		_ = client.Send(NewError(fmt.Sprintf("INCOMPLETE_DATAGRAM: Not every fragment of datagram %d has arrived", msg.ID)))
+		return
+	}
+
+	if msg.Payload != datagram.Data() {
+		_ = client.Send(NewError(fmt.Sprintf("BAD_REASSEMBLY: Datagram %d was not reassembled correctly", msg.ID)))
+		return
+	}
+
+	datagram.Reassembled = true
+	room.sendDatagramState(datagram)
+}
+
+// sendDatagramState tells both ends about the datagram's progress
+func (room *Room) sendDatagramState(datagram *Datagram) {
+	update := NewDatagramStateMessage(*datagram)
+	for _, ip := range []IP{datagram.Source, datagram.Dest} {
+		if client := room.ClientByIP(ip); client != nil {
+			_ = client.Send(update)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPathMTU(t *testing.T) {
+	room := NewRoom("TEST")
+	room.Options.MTU = map[int]int{1: 8, 2: 4}
+
+	tests := []struct {
+		name string
+		a, b int
+		want int
+	}{
+		{"same subnet", 1, 1, 8},
+		{"smallest along the path", 1, 2, 4},
+		{"one end unlimited", 1, 3, 8},
+		{"both ends unlimited", 3, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := room.PathMTU(tt.a, tt.b); got != tt.want {
+				t.Errorf("PathMTU(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFragment(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments []Fragment
+		wantErr   string
+		complete  bool
+	}{
+		{"in order", []Fragment{{0, true, "abcd"}, {4, false, "ef"}}, "", true},
+		{"out of order", []Fragment{{4, false, "ef"}, {0, true, "abcd"}}, "", true},
+		{"missing the middle", []Fragment{{0, true, "ab"}, {4, false, "ef"}}, "", false},
+		{"larger than the MTU", []Fragment{{0, true, "abcde"}}, "FRAGMENT_TOO_LARGE", false},
+		{"past the end", []Fragment{{4, false, "efg"}}, "BAD_OFFSET", false},
+		{"last fragment with more-fragments set", []Fragment{{4, true, "ef"}}, "BAD_FLAGS", false},
+		{"overlapping", []Fragment{{0, true, "abcd"}, {2, true, "cd"}}, "OVERLAPPING_FRAGMENT", false},
+		{"corrupt", []Fragment{{0, true, "abcX"}}, "CORRUPT_FRAGMENT", false},
+		{"empty", []Fragment{{0, true, ""}}, "EMPTY_FRAGMENT", false},
+		{"already complete", []Fragment{{0, true, "abcd"}, {4, false, "ef"}, {0, true, "ab"}}, "DATAGRAM_COMPLETE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			datagram := &Datagram{ID: 1, MTU: 4, payload: "abcdef"}
+			var err error
+			for _, fragment := range tt.fragments {
+				if err = datagram.addFragment(fragment); err != nil {
+					break
+				}
+			}
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("addFragment() = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Fatalf("addFragment() = %v, want %s", err, tt.wantErr)
+			}
+			if got := datagram.Complete(); got != tt.complete {
+				t.Errorf("Complete() = %v, want %v", got, tt.complete)
+			}
+			if tt.complete && datagram.Data() != "abcdef" {
+				t.Errorf("Data() = %q", datagram.Data())
+			}
+		})
+	}
+}
+
+func TestFragmentRunes(t *testing.T) {
+	// Offsets and the MTU count characters, not bytes
+	datagram := &Datagram{ID: 1, MTU: 2, payload: "★☆●"}
+	for _, fragment := range []Fragment{{0, true, "★☆"}, {2, false, "●"}} {
+		if err := datagram.addFragment(fragment); err != nil {
+			t.Fatalf("addFragment(%+v) = %v", fragment, err)
+		}
+	}
+	if !datagram.Complete() || datagram.Data() != "★☆●" {
+		t.Errorf("Complete() = %v, Data() = %q", datagram.Complete(), datagram.Data())
+	}
+}
+
+func TestRejectedOptionsLeaveTheRoomAlone(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid mtu", `{"mtu":{"2":5}}`, http.StatusOK},
+		{"mtu for a missing subnet", `{"mtu":{"2":5,"99":3}}`, http.StatusBadRequest},
+		{"invalid achievements", `{"achievements":[{"id":"first-contact","rule":"nonsense"}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 0, func(opts *RoomOptions) {
+				opts.MTU = map[int]int{1: 10}
+			})
+			room.State.State = Waiting
+			achievement := room.Options.Achievements[0]
+
+			w := room.serve(t, OptionsHandler, http.MethodPost, true, "", tt.body, nil)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				return
+			}
+			if len(room.Options.MTU) != 1 || room.Options.MTU[1] != 10 {
+				t.Errorf("MTU = %v after a rejected update", room.Options.MTU)
+			}
+			if room.Options.Achievements[0] != achievement {
+				t.Errorf("achievement = %+v after a rejected update", room.Options.Achievements[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// A QuestionGenerator creates a networking question along with its computed answer
+type QuestionGenerator func() (question, answer string)
+
+// QuestionGenerators is every generator a room can choose from
+var QuestionGenerators = map[string]QuestionGenerator{
+	"network-address":   networkAddressQuestion,
+	"broadcast-address": broadcastAddressQuestion,
+	"usable-hosts":      usableHostsQuestion,
+	"binary-to-hex":     binaryToHexQuestion,
+	"hex-to-binary":     hexToBinaryQuestion,
+	"binary-to-decimal": binaryToDecimalQuestion,
+	"port-numbers":      portNumberQuestion,
+}
+
+// WellKnownPorts maps protocols to their well-known port
+var WellKnownPorts = map[string]int{
+	"FTP":    21,
+	"SSH":    22,
+	"Telnet": 23,
+	"SMTP":   25,
+	"DNS":    53,
+	"DHCP":   67,
+	"HTTP":   80,
+	"POP3":   110,
+	"NTP":    123,
+	"IMAP":   143,
+	"SNMP":   161,
+	"LDAP":   389,
+	"HTTPS":  443,
+	"SMTPS":  465,
+	"IMAPS":  993,
+	"RDP":    3389,
+}
+
+// Returns a random private IPv4 address and a prefix length between min and max
+func randomCIDR(minPrefix, maxPrefix int) (uint32, int) {
+	addr := uint32(192)<<24 | uint32(168)<<16 | uint32(rand.Intn(256))<<8 | uint32(rand.Intn(256))
+	return addr, minPrefix + rand.Intn(maxPrefix-minPrefix+1)
+}
+
+// Formats a 32-bit address as a dotted quad
+func dottedQuad(addr uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", addr>>24, addr>>16&0xFF, addr>>8&0xFF, addr&0xFF)
+}
+
+// Returns the network mask of a prefix length
+func prefixMask(prefix int) uint32 {
+	return ^uint32(0) << (32 - prefix)
+}
+
+// "Network address of 192.168.3.77/26" -> "192.168.3.64"
+func networkAddressQuestion() (string, string) {
+	addr, prefix := randomCIDR(20, 30)
+	return fmt.Sprintf("Network address of %s/%d", dottedQuad(addr), prefix), dottedQuad(addr & prefixMask(prefix))
+}
+
+// "Broadcast address of 192.168.3.77/26" -> "192.168.3.127"
+func broadcastAddressQuestion() (string, string) {
+	addr, prefix := randomCIDR(20, 30)
+	return fmt.Sprintf("Broadcast address of %s/%d", dottedQuad(addr), prefix), dottedQuad(addr | ^prefixMask(prefix))
+}
+
+// "Usable hosts in a /26" -> "62"
+func usableHostsQuestion() (string, string) {
+	prefix := 16 + rand.Intn(15)
+	return fmt.Sprintf("Usable hosts in a /%d", prefix), fmt.Sprint(1<<(32-prefix) - 2)
+}
+
+// "Hex of 10110011" -> "B3"
+func binaryToHexQuestion() (string, string) {
+	n := rand.Intn(256)
+	return fmt.Sprintf("Hex of %08b", n), fmt.Sprintf("%02X", n)
+}
+
+// "Binary of 0xB3" -> "10110011"
+func hexToBinaryQuestion() (string, string) {
+	n := rand.Intn(256)
+	return fmt.Sprintf("Binary of 0x%02X", n), fmt.Sprintf("%08b", n)
+}
+
+// "Decimal of 10110011" -> "179"
+func binaryToDecimalQuestion() (string, string) {
+	n := rand.Intn(256)
+	return fmt.Sprintf("Decimal of %08b", n), fmt.Sprint(n)
+}
+
+// "Port for HTTPS" -> "443"
+func portNumberQuestion() (string, string) {
+	protocol, port := RandomEntry(WellKnownPorts)
+	return "Port for " + protocol, fmt.Sprint(port)
+}
+
+// RadixPrefixes are the prefixes answers to a generator's questions may also be written
+// with ("0xB3" for "B3"). Every other answer has to be written as is
+var RadixPrefixes = map[string]string{
+	"binary-to-hex": "0x",
+	"hex-to-binary": "0b",
+}
+
+// NormalizeAnswer puts an answer into a canonical form so that equivalent answers compare equal
+//
+// Case and whitespace are ignored
+func NormalizeAnswer(answer string) string {
+	return strings.ToLower(strings.Join(strings.Fields(answer), ""))
+}
+
+// Matches returns true if answer is equivalent to the challenge's answer
+func (result ChallengeResult) Matches(challenge Challenge, answer string) bool {
+	answer = NormalizeAnswer(answer)
+	if trimmed := strings.TrimPrefix(answer, result.Radix); result.Radix != "" && trimmed != "" {
+		answer = trimmed
+	}
+	return answer == NormalizeAnswer(challenge.Answer)
+}
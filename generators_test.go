@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestQuestionGenerators(t *testing.T) {
+	// Every generator's answer is checked against the standard library
+	check := map[string]func(question, answer string) error{
+		"network-address": func(question, answer string) error {
+			_, network, err := net.ParseCIDR(strings.TrimPrefix(question, "Network address of "))
+			if err != nil || network.IP.String() != answer {
+				return fmt.Errorf("want %v (%v)", network, err)
+			}
+			return nil
+		},
+		"broadcast-address": func(question, answer string) error {
+			_, network, err := net.ParseCIDR(strings.TrimPrefix(question, "Broadcast address of "))
+			if err != nil {
+				return err
+			}
+			broadcast := make(net.IP, 4)
+			for i, b := range network.IP.To4() {
+				broadcast[i] = b | ^network.Mask[i]
+			}
+			if broadcast.String() != answer {
+				return fmt.Errorf("want %s", broadcast)
+			}
+			return nil
+		},
+		"usable-hosts": func(question, answer string) error {
+			prefix, _ := strconv.Atoi(strings.TrimPrefix(question, "Usable hosts in a /"))
+			if want := fmt.Sprint(1<<(32-prefix) - 2); answer != want {
+				return fmt.Errorf("want %s", want)
+			}
+			return nil
+		},
+		"binary-to-hex": func(question, answer string) error {
+			n, _ := strconv.ParseUint(strings.TrimPrefix(question, "Hex of "), 2, 8)
+			if got, _ := strconv.ParseUint(answer, 16, 8); got != n || len(answer) != 2 {
+				return fmt.Errorf("want %02X", n)
+			}
+			return nil
+		},
+		"hex-to-binary": func(question, answer string) error {
+			n, _ := strconv.ParseUint(strings.TrimPrefix(question, "Binary of 0x"), 16, 8)
+			if got, _ := strconv.ParseUint(answer, 2, 8); got != n || len(answer) != 8 {
+				return fmt.Errorf("want %08b", n)
+			}
+			return nil
+		},
+		"binary-to-decimal": func(question, answer string) error {
+			n, _ := strconv.ParseUint(strings.TrimPrefix(question, "Decimal of "), 2, 8)
+			if answer != fmt.Sprint(n) {
+				return fmt.Errorf("want %d", n)
+			}
+			return nil
+		},
+		"port-numbers": func(question, answer string) error {
+			if want := WellKnownPorts[strings.TrimPrefix(question, "Port for ")]; answer != fmt.Sprint(want) {
+				return fmt.Errorf("want %d", want)
+			}
+			return nil
+		},
+	}
+
+	for name, generator := range QuestionGenerators {
+		t.Run(name, func(t *testing.T) {
+			if check[name] == nil {
+				t.Fatal("no check for this generator")
+			}
+			for i := 0; i < 100; i++ {
+				question, answer := generator()
+				if err := check[name](question, answer); err != nil {
+					t.Fatalf("%q -> %q: %v", question, answer, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		radix  string
+		given  string
+		want   bool
+	}{
+		{"exact", "B3", "", "B3", true},
+		{"case and whitespace", "B3", "", " b 3 ", true},
+		{"generated hex with its prefix", "B3", "0x", "0xB3", true},
+		{"generated binary with its prefix", "10110011", "0b", "0b10110011", true},
+		{"generated hex with the wrong prefix", "B3", "0x", "0bB3", false},
+		{"a prefix alone", "0B", "0x", "0x", false},
+		{"hex alphabet entry", "0B1F", "", "0B1F", true},
+		{"hex alphabet entry without its leading symbols", "0B1F", "", "1F", false},
+		{"bank answer starting with 0x", "0x1F", "", "0x1F", true},
+		{"bank answer without its 0x", "0x1F", "", "1F", false},
+		{"decimal answers take no prefix", "443", "", "0x443", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ChallengeResult{Radix: tt.radix}
+			if got := result.Matches(Challenge{Answer: tt.answer}, tt.given); got != tt.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.answer, tt.given, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedRadix(t *testing.T) {
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.Generators = []string{"binary-to-hex"}
+	})
+	challenge, result := room.request(t, room.players[0])
+	if result.Radix != "0x" {
+		t.Fatalf("%q has radix %q, want 0x", challenge.Question, result.Radix)
+	}
+
+	room.Answer(room.players[0], AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: "0x" + challenge.Answer})
+	if !room.result(challenge).Correct {
+		t.Errorf("0x%s was not accepted for %q", challenge.Answer, challenge.Question)
+	}
+}
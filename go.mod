@@ -6,4 +6,5 @@ require (
 	github.com/gorilla/websocket v1.5.1
 	github.com/gorilla/mux v1.8.1
 	golang.org/x/net v0.17.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
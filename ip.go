@@ -4,6 +4,9 @@ import (
 	"fmt"
 )
 
+// BroadcastHost is the host number of every subnet's broadcast address
+const BroadcastHost = 255
+
 type IP struct {
 	// Subnet
 	Subnet int
@@ -11,6 +14,20 @@ type IP struct {
 	Host int
 }
 
+// ParseIP parses a "192.168.N.M" address
+func ParseIP(s string) (IP, error) {
+	var ip IP
+	if _, err := fmt.Sscanf(s, "192.168.%d.%d", &ip.Subnet, &ip.Host); err != nil {
+		return IP{}, fmt.Errorf("invalid IP address %q", s)
+	}
+	return ip, nil
+}
+
+// BroadcastAddress returns the broadcast address of a subnet (192.168.N.255)
+func BroadcastAddress(subnet int) IP {
+	return IP{subnet, BroadcastHost}
+}
+
 func (ip IP) String() string {
 	return fmt.Sprintf("192.168.%d.%d", ip.Subnet, ip.Host)
 }
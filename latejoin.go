@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The policies for joining (or changing) a subnet once the game has started
+const (
+	// Subnets can only be joined while the room is waiting
+	ClosedPolicy = "closed"
+	// Players are placed into the least populated subnet straight away
+	AutoPolicy = "auto"
+	// The host has to approve every request
+	ApprovePolicy = "approve"
+)
+
+// SubnetRequest is a request to join (or move to) a subnet after the game started, waiting on the host
+type SubnetRequest struct {
+	// The player asking
+	Player Name `json:"player"`
+
+	// The player's current address (nil for newcomers)
+	From *IP `json:"from,omitempty"`
+
+	// The subnet the player asked for
+	Subnet int `json:"subnet"`
+
+	// When the player asked
+	Time time.Time `json:"time"`
+}
+
+// LeastPopulatedSubnet returns the reachable subnet with the fewest players (0 if every subnet is down)
+func (room *Room) LeastPopulatedSubnet() int {
+	best := 0
+	for subnet := 1; subnet <= room.Metadata.NumSubnets; subnet++ {
+		if room.Metadata.Network.IsDown(subnet) {
+			continue
+		}
+		if best == 0 || len(room.Metadata.Subnets[subnet]) < len(room.Metadata.Subnets[best]) {
+			best = subnet
+		}
+	}
+	return best
+}
+
+// address returns the client's address. Players waiting on the host to let them
+// join have none yet, and are told so
+//
+// The caller must hold the room's lock
+func (room *Room) address(client *Client) (IP, bool) {
+	ip, ok := room.Metadata.IPAddresses[client.Name]
+	if !ok {
+		_ = client.Send(NewError("NO_IP: Join a subnet first"))
+	}
+	return ip, ok
+}
+
+// movePlayer moves a player to another subnet in the middle of a game
+//
+// The old address is retired so the player keeps their points. Challenges the
+// player sent follow them to their new address, challenges sent to the old
+// address can no longer be delivered and expire.
+//
+// The caller must hold the room's lock
+func (room *Room) movePlayer(name Name, subnet int) (IP, []expiredChallenge, error) {
+	old, ok := room.Metadata.IPAddresses[name]
+	if !ok || old.Subnet == subnet {
+		return IP{}, nil, fmt.Errorf("%s can't be moved to subnet %d", name, subnet)
+	}
+	if room.Metadata.Network.IsDown(subnet) {
+		return IP{}, nil, fmt.Errorf("subnet %d is down", subnet)
+	}
+
+	room.leaveSubnet(name)
+	room.Retired[old] = name
+	ip, ok := room.assignAddress(name, subnet)
+	if !ok {
+		room.assignAddress(name, old.Subnet)
+		delete(room.Retired, old)
+		return IP{}, nil, fmt.Errorf("subnet %d is full", subnet)
+	}
+
+	// Outstanding challenges, and their datagrams, connections and translations, follow the sender
+	if room.Metadata.NAT != nil {
+		room.Metadata.NAT.Remap(old, ip)
+	}
+	for challenge, result := range room.Challenges {
+		if challenge.SourceIP != old.String() || !result.Outstanding() {
+			continue
+		}
+		delete(room.Challenges, challenge)
+		challenge.SourceIP = ip.String()
+		room.record(challenge, result)
+	}
+	for key, datagram := range room.Datagrams {
+		if key.Source == old {
+			delete(room.Datagrams, key)
+			datagram.Source = ip
+			room.Datagrams[DatagramKey{ip, key.ID}] = datagram
+		}
+	}
+	for key, conn := range room.Connections {
+		if key.Client == old || key.Server == old {
+			delete(room.Connections, key)
+			if conn.Client == old {
+				conn.Client = ip
+			}
+			if conn.Server == old {
+				conn.Server = ip
+			}
+			room.Connections[ConnectionKey{conn.Client, conn.Server}] = conn
+		}
+	}
+
+	expired := room.expireBroken(func(source, target IP) bool {
+		return source == old || target == old
+	})
+	return ip, expired, nil
+}
+
+// JoinLate is called to handle a JoinSubnet message once the game has started
+func (room *Room) JoinLate(client *Client, msg JoinSubnetMessage) {
+	room.Lock()
+	old, joined := room.Metadata.IPAddresses[client.Name]
+
+	// Newcomers and players changing subnet have their own policies
+	policy := room.Options.LateJoin
+	if joined {
+		policy = room.Options.SubnetChanges
+	}
+
+	switch {
+	case policy == ClosedPolicy:
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Attempted to join subnet while game is not waiting (state: %d)", room.State.State)))
+		room.Unlock()
+		return
+	case msg.Subnet < 0 || msg.Subnet > room.Metadata.NumSubnets:
+		_ = client.Send(NewError(fmt.Sprintf("INVALID_SUBNET: Subnet %d does not exist. Expected 1 <= subnet <= %d", msg.Subnet, room.Metadata.NumSubnets)))
+		room.Unlock()
+		return
+	case policy == AutoPolicy && !joined:
+		var ip IP
+		var ok bool
+		if subnet := room.LeastPopulatedSubnet(); subnet != 0 {
+			ip, ok = room.assignAddress(client.Name, subnet)
+		}
+		if !ok {
+			_ = client.Send(NewError("SUBNET_FULL: There is no room left for you"))
+			room.Unlock()
+			return
+		}
+		room.UpdateScoreboard()
+		_ = client.Send(NewAssignedIPMessage(ip))
+		room.Unlock()
+
+		room.SendUserdata(client)
+		room.BroadcastMetadata()
+		room.BroadcastGameState()
+		return
+	}
+
+	// Everything else waits for the host (newcomers can leave the subnet up to the host)
+	request := SubnetRequest{
+		Player: client.Name,
+		Subnet: msg.Subnet,
+		Time:   time.Now(),
+	}
+	if joined {
+		request.From = &old
+	}
+	room.Pending[client.Name] = request
+	_ = client.Send(NewSubnetRequestedMessage(request))
+	room.Unlock()
+}
+
+// ResolveSubnetRequest approves (or denies) a player's pending subnet request
+func (room *Room) ResolveSubnetRequest(name string, approve bool) error {
+	room.Lock()
+	var client *Client
+	var request SubnetRequest
+	for _, c := range room.Clients {
+		if r, ok := room.Pending[c.Name]; ok && c.Name.String() == name {
+			client, request = c, r
+			break
+		}
+	}
+	if client == nil {
+		room.Unlock()
+		return fmt.Errorf("%s has no pending request", name)
+	}
+	delete(room.Pending, client.Name)
+
+	if !approve {
+		_ = client.Send(NewError(fmt.Sprintf("DENIED: The host denied your request to join subnet %d", request.Subnet)))
+		room.Unlock()
+		return nil
+	}
+
+	subnet := request.Subnet
+	if subnet == 0 {
+		subnet = room.LeastPopulatedSubnet()
+	}
+	if subnet == 0 {
+		room.Unlock()
+		return fmt.Errorf("every subnet is down")
+	}
+
+	var ip IP
+	var expired []expiredChallenge
+	var err error
+	if _, joined := room.Metadata.IPAddresses[client.Name]; joined {
+		ip, expired, err = room.movePlayer(client.Name, subnet)
+	} else if room.Metadata.Network.IsDown(subnet) {
+		err = fmt.Errorf("subnet %d is down", subnet)
+	} else if assigned, ok := room.assignAddress(client.Name, subnet); ok {
+		ip = assigned
+	} else {
+		err = fmt.Errorf("subnet %d is full", subnet)
+	}
+	if err != nil {
+		room.Unlock()
+		return err
+	}
+
+	room.UpdateScoreboard()
+	_ = client.Send(NewAssignedIPMessage(ip))
+	room.Unlock()
+
+	notifyExpired(expired)
+	room.SendUserdata(client)
+	room.BroadcastMetadata()
+	room.BroadcastGameState()
+	return nil
+}
+
+// RequestsHandler lists the pending subnet requests
+// /room/{code}/requests
+func RequestsHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	room.RLock()
+	requests := make([]SubnetRequest, 0, len(room.Pending))
+	for _, request := range room.Pending {
+		requests = append(requests, request)
+	}
+	room.RUnlock()
+
+	// Oldest first
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Time.Before(requests[j].Time)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(requests)
+}
+
+// ResolveRequestHandler lets the host approve or deny a player's subnet request
+// /room/{code}/requests/{name}?approve=<true|false>&key=<key>
+func ResolveRequestHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := vars["code"]
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeHost(w, r, room) {
+		return
+	}
+
+	if err := room.ResolveSubnetRequest(vars["name"], r.FormValue("approve") == "true"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
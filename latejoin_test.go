@@ -0,0 +1,290 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeastPopulatedSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		down    []int
+		want    int
+	}{
+		{"empty room", 0, nil, 1},
+		{"fewest players", 3, nil, 4},
+		{"fewest players is down", 3, []int{4}, 1},
+		{"every subnet is down", 3, []int{1, 2, 3, 4}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, tt.players, nil)
+			room.Metadata.Network.Down = tt.down
+			if got := room.LeastPopulatedSubnet(); got != tt.want {
+				t.Errorf("LeastPopulatedSubnet = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoJoin(t *testing.T) {
+	tests := []struct {
+		name   string
+		down   []int
+		subnet int
+	}{
+		{"joins the emptiest subnet", nil, 2},
+		{"skips subnets that are down", []int{2}, 3},
+		{"every subnet is down", []int{1, 2, 3, 4}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 5, func(opts *RoomOptions) {
+				opts.LateJoin = AutoPolicy
+			})
+			room.Metadata.Network.Down = tt.down
+
+			newcomer := room.NewClient()
+			newcomer.send = make(chan []byte, 1024)
+			room.players = append(room.players, newcomer)
+			room.JoinSubnet(newcomer, JoinSubnetMessage{Subnet: 1})
+
+			ip, joined := room.Metadata.IPAddresses[newcomer.Name]
+			if tt.subnet == 0 {
+				if joined {
+					t.Errorf("joined %v while every subnet is down", ip)
+				}
+				if errs := room.failures(newcomer); len(errs) != 1 || !strings.HasPrefix(errs[0], "SUBNET_FULL") {
+					t.Errorf("errors = %v, want SUBNET_FULL", errs)
+				}
+				return
+			}
+
+			if ip.Subnet != tt.subnet {
+				t.Errorf("joined %v, want subnet %d", ip, tt.subnet)
+			}
+			if _, ok := room.State.Scoreboard[newcomer.Name]; !ok {
+				t.Errorf("%s is not on the scoreboard", newcomer.Name)
+			}
+			if len(room.received(room.players[0], GameState)) == 0 {
+				t.Errorf("the other players weren't sent the new scoreboard")
+			}
+		})
+	}
+}
+
+func TestResolveSubnetRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnet  int
+		down    []int
+		approve bool
+		want    int
+		wantErr bool
+	}{
+		{"approved", 2, nil, true, 2, false},
+		{"denied", 2, nil, false, 1, false},
+		{"into a subnet that is down", 2, []int{2}, true, 1, true},
+		{"anywhere while every other subnet is down", 0, []int{2, 3, 4}, true, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 1, func(opts *RoomOptions) {
+				opts.SubnetChanges = ApprovePolicy
+			})
+			player := room.players[0]
+			room.JoinSubnet(player, JoinSubnetMessage{Subnet: tt.subnet})
+			room.Metadata.Network.Down = tt.down
+
+			err := room.ResolveSubnetRequest(player.Name.String(), tt.approve)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResolveSubnetRequest error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := room.ip(player).Subnet; got != tt.want {
+				t.Errorf("player is in subnet %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovePlayer(t *testing.T) {
+	room := newTestRoom(t, 3, func(opts *RoomOptions) {
+		opts.PublicSubnet = 4
+	})
+	mover, other := room.players[0], room.players[1]
+	old, peer := room.ip(mover), room.ip(other)
+
+	challenge := func(id int, source, dest IP, result ChallengeResult) Challenge {
+		c := Challenge{ID: id, SourceIP: source.String(), DestIP: dest.String(), Question: "Q", Answer: "A"}
+		result.Created = time.Now()
+		room.Challenges[c] = result
+		return c
+	}
+	sent := challenge(1, old, peer, ChallengeResult{})
+	answered := challenge(2, old, peer, ChallengeResult{Correct: true})
+	received := challenge(3, peer, old, ChallengeResult{})
+
+	room.Datagrams[DatagramKey{old, 7}] = &Datagram{ID: 7, Source: old, Dest: peer}
+	room.Connections[ConnectionKey{old, peer}] = &TCPConnection{Client: old, Server: peer}
+	room.Metadata.NAT.Translate(old)
+
+	room.Lock()
+	ip, expired, err := room.movePlayer(mover.Name, 3)
+	room.Unlock()
+	if err != nil {
+		t.Fatalf("movePlayer: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"the player has a new address", ip.Subnet == 3 && room.ip(mover) == ip},
+		{"the old address is retired", room.Retired[old] == mover.Name},
+		{"outstanding challenges follow the sender", func() bool {
+			sent.SourceIP = ip.String()
+			_, ok := room.Challenges[sent]
+			return ok
+		}()},
+		{"answered challenges keep the old address", func() bool {
+			_, ok := room.Challenges[answered]
+			return ok
+		}()},
+		{"challenges to the old address expire", room.result(received).Expired && len(expired) == 1},
+		{"datagrams follow the sender", room.Datagrams[DatagramKey{ip, 7}] != nil && room.Datagrams[DatagramKey{ip, 7}].Source == ip},
+		{"connections follow the player", room.Connections[ConnectionKey{ip, peer}] != nil && room.Connections[ConnectionKey{old, peer}] == nil},
+		{"translations follow the sender", func() bool {
+			moved := false
+			for _, mapping := range room.Metadata.NAT.Mappings {
+				if mapping.PrivateIP == old {
+					return false
+				}
+				moved = moved || mapping.PrivateIP == ip
+			}
+			return moved
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.ok {
+				t.Errorf("after moving from %v to %v, it is not the case that %s", old, ip, tt.name)
+			}
+		})
+	}
+}
+
+func TestMovePlayerRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		subnet int
+		down   []int
+	}{
+		{"same subnet", 1, nil},
+		{"subnet is down", 2, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 1, nil)
+			player := room.players[0]
+			old := room.ip(player)
+			room.Metadata.Network.Down = tt.down
+
+			room.Lock()
+			_, _, err := room.movePlayer(player.Name, tt.subnet)
+			room.Unlock()
+			if err == nil {
+				t.Fatalf("moved into subnet %d", tt.subnet)
+			}
+			if room.ip(player) != old || len(room.Retired) != 0 {
+				t.Errorf("a refused move changed the player's address")
+			}
+		})
+	}
+}
+
+func TestPendingNewcomersCantPlay(t *testing.T) {
+	tests := []struct {
+		name string
+		act  func(room *testRoom, newcomer *Client)
+	}{
+		{"request a challenge", func(room *testRoom, newcomer *Client) {
+			room.RequestChallenge(newcomer, RequestChallengeMessage{})
+		}},
+		{"answer", func(room *testRoom, newcomer *Client) {
+			room.Answer(newcomer, AnswerMessage{Destination: room.ip(room.players[0]).String(), Question: "Q", Answer: "A"})
+		}},
+		{"verify", func(room *testRoom, newcomer *Client) {
+			room.Verify(newcomer, VerifyMessage{Destination: room.ip(room.players[0]).String(), Question: "Q", Answer: "A"})
+		}},
+		{"reply", func(room *testRoom, newcomer *Client) {
+			room.Reply(newcomer, ReplyMessage{Source: room.ip(room.players[0]).String(), Question: "Q", Answer: "A"})
+		}},
+		{"relay", func(room *testRoom, newcomer *Client) {
+			room.Relay(newcomer, RelayMessage{Source: room.ip(room.players[0]).String()})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.LateJoin = ApprovePolicy
+			})
+			newcomer := room.NewClient()
+			newcomer.send = make(chan []byte, 1024)
+			room.JoinSubnet(newcomer, JoinSubnetMessage{Subnet: 3})
+			if _, pending := room.Pending[newcomer.Name]; !pending {
+				t.Fatalf("the newcomer isn't waiting on the host")
+			}
+			room.discard()
+
+			tt.act(room, newcomer)
+
+			if errs := room.failures(newcomer); len(errs) != 1 || !strings.HasPrefix(errs[0], "NO_IP") {
+				t.Errorf("errors = %v, want NO_IP", errs)
+			}
+			if len(room.Challenges) != 0 {
+				t.Errorf("%d challenges were created", len(room.Challenges))
+			}
+		})
+	}
+}
+
+func TestResolveRequestHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		host   bool
+		status int
+		moved  bool
+	}{
+		{"host approves", http.MethodPost, true, http.StatusOK, true},
+		{"player approves their own move", http.MethodPost, false, http.StatusForbidden, false},
+		{"GET", http.MethodGet, true, http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 1, func(opts *RoomOptions) {
+				opts.SubnetChanges = ApprovePolicy
+			})
+			player := room.players[0]
+			room.JoinSubnet(player, JoinSubnetMessage{Subnet: 2})
+
+			w := room.serve(t, ResolveRequestHandler, tt.method, tt.host, "approve=true", "", map[string]string{"name": player.Name.String()})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if moved := room.ip(player).Subnet == 2; moved != tt.moved {
+				t.Errorf("player moved: %v, want %v", moved, tt.moved)
+			}
+		})
+	}
+}
@@ -11,7 +11,7 @@ import (
 func main() {
 	// Create default room
 	room := rooms.NewRoom("")
-	log.Println("Created room", room.code)
+	log.Println("Created room", room.code, "with host key", room.hostKey)
 
 	// Build the HTTP router
 	router := mux.NewRouter()
@@ -35,7 +35,7 @@ func main() {
 	// Room control
 	router.HandleFunc("/room/new", func(w http.ResponseWriter, r *http.Request) {
 		room := rooms.NewRoom("")
-		log.Println("Created room", room.code)
+		log.Println("Created room", room.code, "with host key", room.hostKey)
 		http.Redirect(w, r, "/room/"+room.code, http.StatusFound)
 	})
 
@@ -57,6 +57,34 @@ func main() {
 	// Register Handler
 	router.HandleFunc("/room/{code}/register", RegisterHandler)
 
+	// Options Handler
+	router.HandleFunc("/room/{code}/options", OptionsHandler)
+
+	// Game life-cycle
+	router.HandleFunc("/room/{code}/start", StartHandler)
+	router.HandleFunc("/room/{code}/stop", StopHandler)
+	router.HandleFunc("/room/{code}/restart", RestartHandler)
+
+	// Public state, for the projector
+	router.HandleFunc("/room/{code}/state", StateHandler)
+
+	// Late joiners and subnet changes
+	router.HandleFunc("/room/{code}/requests", RequestsHandler)
+	router.HandleFunc("/room/{code}/requests/{name}", ResolveRequestHandler)
+
+	// Network events
+	router.HandleFunc("/room/{code}/events", EventsHandler)
+
+	// Secret roles
+	router.HandleFunc("/room/{code}/roles/{name}", RoleHandler)
+
+	// Team names
+	router.HandleFunc("/room/{code}/teams/{subnet}", RenameTeamHandler)
+
+	// Question bank library
+	router.HandleFunc("/banks", BanksHandler)
+	router.HandleFunc("/banks/{name}", BankHandler)
+
 	// Start the HTTP server
 	log.Println("Starting HTTP server")
 	log.Println("Listening at http://localhost:8080/room/" + room.code)
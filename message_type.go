@@ -10,6 +10,20 @@ const (
 	Answer
 	RequestMetadata
 	RequestUserdata
+	Resolve
+	Syn
+	SynAck
+	Ack
+	Data
+	Fin
+	SendFragment
+	Reassemble
+	Relay
+	Spoof
+	Verify
+	Sniff
+	Shield
+	Reply
 
 	// Server -> Client
 	AssignedIP
@@ -17,6 +31,21 @@ const (
 	Grade
 	Metadata
 	Userdata
+	Resolved
+	ConnectionState
+	DatagramState
+	Expired
+	GameState
+	Progress
+	RoundEnd
+	Intercepted
+	Verified
+	Event
+	SubnetRequested
+	BadgeEarned
+	Storm
+	Translated
+	Replied
 
 	// Host -> All
 	Start
@@ -35,12 +64,41 @@ var messageTypeStrings = [...]string{
 	"Answer",
 	"RequestMetadata",
 	"RequestUserdata",
+	"Resolve",
+	"Syn",
+	"SynAck",
+	"Ack",
+	"Data",
+	"Fin",
+	"SendFragment",
+	"Reassemble",
+	"Relay",
+	"Spoof",
+	"Verify",
+	"Sniff",
+	"Shield",
+	"Reply",
 
 	"AssignedIP",
 	"CreateChallenge",
 	"Grade",
 	"Metadata",
 	"Userdata",
+	"Resolved",
+	"ConnectionState",
+	"DatagramState",
+	"Expired",
+	"GameState",
+	"Progress",
+	"RoundEnd",
+	"Intercepted",
+	"Verified",
+	"Event",
+	"SubnetRequested",
+	"BadgeEarned",
+	"Storm",
+	"Translated",
+	"Replied",
 
 	"Start",
 	"Stop",
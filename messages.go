@@ -69,6 +69,66 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		m.Payload = payload
+	case Resolve:
+		var payload ResolveMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Syn, SynAck, Ack, Data, Fin:
+		var payload SegmentMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case SendFragment:
+		var payload FragmentMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Reassemble:
+		var payload ReassembleMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Relay:
+		var payload RelayMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Spoof:
+		var payload SpoofMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Verify:
+		var payload VerifyMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Sniff:
+		var payload SniffMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Shield:
+		var payload ShieldMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
+	case Reply:
+		var payload ReplyMessage
+		if err := json.Unmarshal(aux.Payload, &payload); err != nil {
+			return err
+		}
+		m.Payload = payload
 	}
 
 	return nil
@@ -94,6 +154,11 @@ type AnswerMessage struct {
 	Question string `json:"question"`
 	// The answer
 	Answer string `json:"answer"`
+	// The responder token that came back with the answer, crediting whoever looked it up
+	// (required for broadcast and multicast challenges, where it identifies the recipient that replied)
+	ResponderToken string `json:"responder_token,omitempty"`
+	// The "ip:port" the destination replied to (required when the challenge was source-NATed)
+	ReplyTo string `json:"reply_to,omitempty"`
 }
 
 // RequestMetaData is sent by the client to the server, asking for updated Metadata
@@ -102,6 +167,109 @@ type RequestMetadataMessage struct{}
 // RequestUserdata is sent by the client to the server, asking for updated user data
 type RequestUserdataMessage struct{}
 
+// ResolveMessage is sent by the client to ask the server's DNS resolver for a hostname's IP address
+type ResolveMessage struct {
+	// The hostname to resolve
+	Hostname string `json:"hostname"`
+}
+
+// SegmentMessage is sent by the client for every TCP segment (Syn, SynAck, Ack, Data and Fin)
+type SegmentMessage struct {
+	// The IP address of the other end of the connection
+	Destination string `json:"destination"`
+	// The segment's sequence number
+	Seq int `json:"seq"`
+	// The segment's acknowledgement number
+	Ack int `json:"ack"`
+	// The segment's data (only for Data segments)
+	Data string `json:"data,omitempty"`
+}
+
+// FragmentMessage is sent by the source of a datagram for every fragment
+type FragmentMessage struct {
+	// The datagram's ID
+	ID int `json:"id"`
+	// The fragment's header and data
+	Fragment
+}
+
+// ReassembleMessage is sent by the destination of a datagram once it has been reassembled
+type ReassembleMessage struct {
+	// The datagram's source IP address
+	Source string `json:"source"`
+	// The datagram's ID
+	ID int `json:"id"`
+	// The reassembled payload
+	Payload string `json:"payload"`
+}
+
+// RelayMessage is sent by an intermediate hop of a chain challenge once it has looked up the next token
+type RelayMessage struct {
+	// The IP address of the host that started the chain
+	Source string `json:"source"`
+	// The question this hop received
+	Question string `json:"question"`
+	// The token looked up in this hop's table (the next hop's question)
+	Token string `json:"token"`
+}
+
+// SpoofMessage is sent by a spoofer to forge a reply to another player's challenge
+type SpoofMessage struct {
+	// The IP address of the challenge's sender (the victim)
+	Source string `json:"source"`
+	// The destination the spoofer is pretending to be
+	Destination string `json:"destination"`
+	// The question being replied to
+	Question string `json:"question"`
+	// The forged answer given to the victim
+	Answer string `json:"answer"`
+}
+
+// VerifyMessage is sent by the client to check the signature of a reply before answering
+type VerifyMessage struct {
+	// The destination IP address the reply claims to be from
+	Destination string `json:"destination"`
+	// The question that was replied to
+	Question string `json:"question"`
+	// The answer in the reply
+	Answer string `json:"answer"`
+	// The responder token in the reply. Only the real destination holds it, so it acts as the reply's signature
+	ResponderToken string `json:"responder_token,omitempty"`
+}
+
+// ReplyMessage is sent by a challenge's destination to send its reply over the network
+// (in TCP mode replies can also travel as DATA segments)
+type ReplyMessage struct {
+	// The IP address of the challenge's sender
+	Source string `json:"source"`
+	// The question being replied to (as it was received)
+	Question string `json:"question"`
+	// The answer looked up in the destination's Q/A table
+	Answer string `json:"answer"`
+	// The destination's responder token, for the sender to relay back
+	ResponderToken string `json:"responder_token,omitempty"`
+}
+
+// SniffMessage is sent by a sniffer to answer a challenge it intercepted
+type SniffMessage struct {
+	// The IP address of the challenge's sender
+	Source string `json:"source"`
+	// The challenge's destination
+	Destination string `json:"destination"`
+	// The intercepted question
+	Question string `json:"question"`
+	// The sniffed answer
+	Answer string `json:"answer"`
+}
+
+// ShieldMessage is sent by the destination of a challenge to refuse it with a shield
+type ShieldMessage struct {
+	// The IP address of the challenge's sender
+	Source string `json:"source"`
+	// The question being refused
+	Question string `json:"question"`
+}
+
 // ---- Server -> Client ---- //
 
 // AssignedIPMessage is sent by the server to confirm joining a subnet, and to assign an IP address
@@ -123,16 +291,35 @@ func NewAssignedIPMessage(ip IP) Message {
 type CreateChallengeMessage struct {
 	// The destination IP address
 	Destination string `json:"destination"`
+	// The source address the destination will see after NAT (only present when translated)
+	//
+	// The destination must address its reply to this "ip:port"
+	Source string `json:"source,omitempty"`
 	// The question
 	Question string `json:"question"`
+	// The datagram the question must be fragmented into (only present when it exceeds the path MTU)
+	Datagram *DatagramHeader `json:"datagram,omitempty"`
+	// Priority packets are worth more points
+	Priority bool `json:"priority,omitempty"`
+}
+
+// DatagramHeader tells the sender how to fragment a question
+type DatagramHeader struct {
+	// The datagram's ID
+	ID int `json:"id"`
+	// The smallest MTU along the path
+	MTU int `json:"mtu"`
 }
 
-func NewCreateChallengeMessage(dest, question string) Message {
+func NewCreateChallengeMessage(dest, source, question string, datagram *DatagramHeader, priority bool) Message {
 	return Message{
 		Type: CreateChallenge,
 		Payload: CreateChallengeMessage{
 			Destination: dest,
+			Source:      source,
 			Question:    question,
+			Datagram:    datagram,
+			Priority:    priority,
 		},
 	}
 }
@@ -145,15 +332,262 @@ type GradeMessage struct {
 	Question string `json:"question"`
 	// If the answer was correct
 	Correct bool `json:"correct"`
+	// The number of hosts that have replied (only for broadcast and multicast challenges)
+	Replies int `json:"replies,omitempty"`
+	// If the responder was credited with the answer
+	Credited bool `json:"credited,omitempty"`
 }
 
-func NewGradeMessage(dest, question string, correct bool) Message {
+func NewGradeMessage(dest, question string, correct bool, replies int, credited bool) Message {
 	return Message{
 		Type: Grade,
 		Payload: GradeMessage{
 			Destination: dest,
 			Question:    question,
 			Correct:     correct,
+			Replies:     replies,
+			Credited:    credited,
+		},
+	}
+}
+
+// ResolvedMessage is sent by the server in response to a Resolve message
+type ResolvedMessage struct {
+	// The resolved record
+	DNSRecord
+}
+
+func NewResolvedMessage(record DNSRecord) Message {
+	return Message{
+		Type: Resolved,
+		Payload: ResolvedMessage{
+			DNSRecord: record,
+		},
+	}
+}
+
+// ConnectionStateMessage is sent by the server to both ends of a connection after every accepted segment
+func NewConnectionStateMessage(conn TCPConnection) Message {
+	return Message{
+		Type:    ConnectionState,
+		Payload: conn,
+	}
+}
+
+// DatagramStateMessage is sent by the server to both ends of a datagram after every accepted fragment
+func NewDatagramStateMessage(datagram Datagram) Message {
+	return Message{
+		Type:    DatagramState,
+		Payload: datagram,
+	}
+}
+
+// ExpiredMessage is sent by the server when a challenge times out before it was answered
+type ExpiredMessage struct {
+	// The destination IP address
+	Destination string `json:"destination"`
+	// The question that was never answered
+	Question string `json:"question"`
+}
+
+func NewExpiredMessage(dest, question string) Message {
+	return Message{
+		Type: Expired,
+		Payload: ExpiredMessage{
+			Destination: dest,
+			Question:    question,
+		},
+	}
+}
+
+// TranslatedMessage is sent by the server to the destination of a source-NATed challenge
+type TranslatedMessage struct {
+	// The only source address the destination can see, and must reply to
+	Source string `json:"source"`
+}
+
+func NewTranslatedMessage(source string) Message {
+	return Message{
+		Type: Translated,
+		Payload: TranslatedMessage{
+			Source: source,
+		},
+	}
+}
+
+// RepliedMessage is sent by the server to deliver a reply to the challenge's sender
+type RepliedMessage struct {
+	// The host that replied
+	Source string `json:"source"`
+	// The question that was replied to
+	Question string `json:"question"`
+	// The answer in the reply
+	Answer string `json:"answer"`
+	// The replying host's responder token
+	ResponderToken string `json:"responder_token,omitempty"`
+}
+
+func NewRepliedMessage(source string, msg ReplyMessage) Message {
+	return Message{
+		Type: Replied,
+		Payload: RepliedMessage{
+			Source:         source,
+			Question:       msg.Question,
+			Answer:         msg.Answer,
+			ResponderToken: msg.ResponderToken,
+		},
+	}
+}
+
+// ProgressMessage is sent by the server (at most once a second) as the class makes progress
+type ProgressMessage struct {
+	// The number of correct answers so far
+	Progress int `json:"progress"`
+	// The number of correct answers needed to finish the game (0 if there is no goal)
+	Goal int `json:"goal,omitempty"`
+}
+
+func NewProgressMessage(progress, goal int) Message {
+	return Message{
+		Type: Progress,
+		Payload: ProgressMessage{
+			Progress: progress,
+			Goal:     goal,
+		},
+	}
+}
+
+// RoundEndMessage is sent by the server when a round of a tournament ends
+type RoundEndMessage struct {
+	// The results of the round
+	Summary RoundSummary `json:"summary"`
+	// Each player's total score over every finished round
+	Standings map[Name]int `json:"standings"`
+	// If this was the last round, making the standings the final results
+	Final bool `json:"final"`
+}
+
+func NewRoundEndMessage(summary RoundSummary, standings map[Name]int, final bool) Message {
+	return Message{
+		Type: RoundEnd,
+		Payload: RoundEndMessage{
+			Summary:   summary,
+			Standings: standings,
+			Final:     final,
+		},
+	}
+}
+
+// InterceptedMessage is sent by the server to players that can see someone else's traffic
+type InterceptedMessage struct {
+	// The IP address of the challenge's sender (or the segment's sender)
+	Source string `json:"source"`
+	// The challenge's destination (or the segment's destination)
+	Destination string `json:"destination"`
+	// The question (as it was transmitted)
+	Question string `json:"question,omitempty"`
+	// The data carried by an intercepted TCP segment
+	Data string `json:"data,omitempty"`
+	// The answer carried by an intercepted reply
+	Answer string `json:"answer,omitempty"`
+}
+
+func NewInterceptedMessage(source, destination, question string) Message {
+	return Message{
+		Type: Intercepted,
+		Payload: InterceptedMessage{
+			Source:      source,
+			Destination: destination,
+			Question:    question,
+		},
+	}
+}
+
+func NewInterceptedReplyMessage(source, destination, question, answer string) Message {
+	return Message{
+		Type: Intercepted,
+		Payload: InterceptedMessage{
+			Source:      source,
+			Destination: destination,
+			Question:    question,
+			Answer:      answer,
+		},
+	}
+}
+
+func NewInterceptedSegmentMessage(source, destination IP, data string) Message {
+	return Message{
+		Type: Intercepted,
+		Payload: InterceptedMessage{
+			Source:      source.String(),
+			Destination: destination.String(),
+			Data:        data,
+		},
+	}
+}
+
+// VerifiedMessage is sent by the server with the result of checking a reply's signature
+type VerifiedMessage struct {
+	// The destination IP address the reply claimed to be from
+	Destination string `json:"destination"`
+	// The question that was replied to
+	Question string `json:"question"`
+	// If the reply was really signed by the destination
+	Authentic bool `json:"authentic"`
+	// The spoofer that forged the reply (if they were caught)
+	Spoofer *IP `json:"spoofer,omitempty"`
+}
+
+func NewVerifiedMessage(destination, question string, authentic bool, spoofer *IP) Message {
+	return Message{
+		Type: Verified,
+		Payload: VerifiedMessage{
+			Destination: destination,
+			Question:    question,
+			Authentic:   authentic,
+			Spoofer:     spoofer,
+		},
+	}
+}
+
+// NewEventMessage is sent by the server when the host injects a network event
+func NewEventMessage(event NetworkEvent) Message {
+	return Message{
+		Type:    Event,
+		Payload: event,
+	}
+}
+
+// NewSubnetRequestedMessage is sent by the server when a subnet request is waiting on the host
+func NewSubnetRequestedMessage(request SubnetRequest) Message {
+	return Message{
+		Type:    SubnetRequested,
+		Payload: request,
+	}
+}
+
+// NewBadgeMessage is sent by the server when a player earns an achievement
+func NewBadgeMessage(badge Badge) Message {
+	return Message{
+		Type:    BadgeEarned,
+		Payload: badge,
+	}
+}
+
+// StormMessage is sent by the server when a broadcast storm starts or ends
+type StormMessage struct {
+	// If the storm is raging (every request creates twice the challenges)
+	Active bool `json:"active"`
+	// When the storm ends
+	Until time.Time `json:"until,omitempty"`
+}
+
+func NewStormMessage(active bool, until time.Time) Message {
+	return Message{
+		Type: Storm,
+		Payload: StormMessage{
+			Active: active,
+			Until:  until,
 		},
 	}
 }
@@ -175,7 +609,12 @@ func NewUserdataMessage(userdata RoomUserData) Message {
 }
 
 // GameStateMessage is sent by the server to provide the current game state
-// func NewGameStateMessage(state RoomState) Message {}
+func NewGameStateMessage(state PublicState) Message {
+	return Message{
+		Type:    GameState,
+		Payload: state,
+	}
+}
 
 // ---- Host -> All ---- //
 
@@ -191,8 +630,25 @@ type StopMessage struct {
 	StopTime time.Time `json:"stop_time"`
 }
 
-// RestartMessage is sent by the host. This evicts all clients from their subnets, prompting them to rejoin and get a new IP address
-type RestartMessage struct{}
+// RestartMessage is sent by the host to begin the next round of a tournament
+//
+// Q/A tables are regenerated, and clients may be moved to a new subnet (followed by an AssignedIP message)
+type RestartMessage struct {
+	// The round that is about to begin
+	Round int `json:"round"`
+	// The number of rounds in the tournament
+	Rounds int `json:"rounds"`
+}
+
+func NewRestartMessage(round, rounds int) Message {
+	return Message{
+		Type: Restart,
+		Payload: RestartMessage{
+			Round:  round,
+			Rounds: rounds,
+		},
+	}
+}
 
 // DestroyMessage is sent by the host. This evicts all clients from the room, and destroys the room
 type DestroyMessage struct{}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+)
+
+// GatewayHost is the host number reserved for the NAT gateway on the public subnet
+const GatewayHost = 254
+
+// The first port handed out by the gateway
+const firstPublicPort = 10000
+
+// The ephemeral port range private hosts send from (49152-65535)
+const (
+	firstEphemeralPort = 49152
+	lastEphemeralPort  = 65535
+)
+
+// NATMapping is a single entry in the gateway's translation table
+type NATMapping struct {
+	// The private address and port of the original sender
+	PrivateIP   IP  `json:"private_ip"`
+	PrivatePort int `json:"private_port"`
+
+	// The public address and port the destination sees (and must reply to)
+	PublicIP   IP  `json:"public_ip"`
+	PublicPort int `json:"public_port"`
+}
+
+// NATTable is the source-NAT gateway between the private subnets and the public subnet
+//
+// The table is part of the room's Metadata so that everyone can see the translations.
+// A mapping only lives as long as the challenge it was created for.
+type NATTable struct {
+	// The public subnet
+	PublicSubnet int `json:"public_subnet"`
+
+	// The gateway's public address
+	Gateway IP `json:"gateway"`
+
+	// The live translations
+	Mappings []NATMapping `json:"mappings"`
+
+	// The next public port to hand out
+	nextPort int
+
+	// The next ephemeral port each private host sends from
+	ephemeral map[IP]int
+}
+
+// NewNATTable creates an empty translation table for the given public subnet
+func NewNATTable(publicSubnet int) *NATTable {
+	return &NATTable{
+		PublicSubnet: publicSubnet,
+		Gateway:      IP{publicSubnet, GatewayHost},
+		Mappings:     []NATMapping{},
+		nextPort:     firstPublicPort,
+		ephemeral:    map[IP]int{},
+	}
+}
+
+// IsPrivate returns true if the address is on the private side of the gateway
+func (nat *NATTable) IsPrivate(ip IP) bool {
+	return ip.Subnet != nat.PublicSubnet
+}
+
+// IsReserved returns true if the address belongs to the gateway
+func (nat *NATTable) IsReserved(ip IP) bool {
+	return ip == nat.Gateway
+}
+
+// ephemeralPort returns the port a private host sends its next packet from
+//
+// Like a real host, ports are handed out in order and wrap around at the end of the range
+func (nat *NATTable) ephemeralPort(private IP) int {
+	port, ok := nat.ephemeral[private]
+	if !ok || port > lastEphemeralPort {
+		port = firstEphemeralPort
+	}
+	nat.ephemeral[private] = port + 1
+	return port
+}
+
+// Translate allocates a new public port for a packet leaving a private host
+func (nat *NATTable) Translate(private IP) NATMapping {
+	mapping := NATMapping{
+		PrivateIP:   private,
+		PrivatePort: nat.ephemeralPort(private),
+		PublicIP:    nat.Gateway,
+		PublicPort:  nat.nextPort,
+	}
+	nat.nextPort++
+	nat.Mappings = append(nat.Mappings, mapping)
+	return mapping
+}
+
+// Lookup finds the live mapping for a public "ip:port", the way the gateway forwards a reply
+func (nat *NATTable) Lookup(public string) (NATMapping, bool) {
+	for _, mapping := range nat.Mappings {
+		if mapping.PublicAddress() == public {
+			return mapping, true
+		}
+	}
+	return NATMapping{}, false
+}
+
+// Release removes the mapping for a public port, returning false if there was none
+func (nat *NATTable) Release(port int) bool {
+	for i, mapping := range nat.Mappings {
+		if mapping.PublicPort == port {
+			nat.Mappings = append(nat.Mappings[:i], nat.Mappings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Remap points a private host's mappings at its new address
+func (nat *NATTable) Remap(old, ip IP) {
+	for i := range nat.Mappings {
+		if nat.Mappings[i].PrivateIP == old {
+			nat.Mappings[i].PrivateIP = ip
+		}
+	}
+}
+
+// PublicAddress is the "ip:port" the destination sees as the packet's source
+func (mapping NATMapping) PublicAddress() string {
+	return fmt.Sprintf("%s:%d", mapping.PublicIP, mapping.PublicPort)
+}
+
+// releaseMapping removes a finished challenge's translation from the gateway
+//
+// Returns true if the table changed (and the room's Metadata needs to be rebroadcast).
+// The caller must hold the room's lock
+func (room *Room) releaseMapping(result ChallengeResult) bool {
+	if room.Metadata.NAT == nil || result.Translation == nil {
+		return false
+	}
+	return room.Metadata.NAT.Release(result.Translation.PublicPort)
+}
+
+// Forwarded returns an error unless a reply sent to the public address reaches the challenge's sender
+//
+// The gateway only forwards replies to a live mapping, and only to the host that created it.
+// The caller must hold the room's lock
+func (room *Room) Forwarded(result ChallengeResult, sender IP, replyTo string) error {
+	if result.Translation == nil {
+		return nil
+	}
+	if replyTo != result.Translation.PublicAddress() {
+		return fmt.Errorf("NOT_TRANSLATED: The reply has to be sent to %s, not %q", result.Translation.PublicAddress(), replyTo)
+	}
+
+	var mapping NATMapping
+	ok := false
+	if room.Metadata.NAT != nil {
+		mapping, ok = room.Metadata.NAT.Lookup(replyTo)
+	}
+	if !ok || mapping.PrivateIP != sender {
+		return fmt.Errorf("NO_MAPPING: The gateway has no mapping for %s, the reply was dropped", replyTo)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEphemeralPort(t *testing.T) {
+	tests := []struct {
+		name  string
+		start int
+		want  []int
+	}{
+		{"first packet", 0, []int{49152, 49153, 49154}},
+		{"wraps around", lastEphemeralPort, []int{65535, 49152, 49153}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nat := NewNATTable(1)
+			host := IP{2, 1}
+			if tt.start != 0 {
+				nat.ephemeral[host] = tt.start
+			}
+			for _, want := range tt.want {
+				if got := nat.ephemeralPort(host); got != want {
+					t.Errorf("ephemeralPort() = %d, want %d", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNATTable(t *testing.T) {
+	nat := NewNATTable(1)
+	a := nat.Translate(IP{2, 1})
+	b := nat.Translate(IP{3, 1})
+	c := nat.Translate(IP{2, 1})
+
+	if a.PublicPort == b.PublicPort || a.PrivatePort == c.PrivatePort {
+		t.Fatalf("ports were reused: %+v %+v %+v", a, b, c)
+	}
+	if got := a.PublicAddress(); got != "192.168.1.254:10000" {
+		t.Errorf("PublicAddress() = %q", got)
+	}
+
+	tests := []struct {
+		name    string
+		release int
+		lookup  string
+		want    bool
+	}{
+		{"live mapping", 0, b.PublicAddress(), true},
+		{"released mapping", b.PublicPort, b.PublicAddress(), false},
+		{"other mappings survive", 0, c.PublicAddress(), true},
+		{"unknown port", 0, "192.168.1.254:1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.release != 0 && !nat.Release(tt.release) {
+				t.Fatalf("Release(%d) = false", tt.release)
+			}
+			if _, ok := nat.Lookup(tt.lookup); ok != tt.want {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.lookup, ok, tt.want)
+			}
+		})
+	}
+
+	nat.Remap(IP{2, 1}, IP{4, 1})
+	for _, mapping := range nat.Mappings {
+		if mapping.PrivateIP == (IP{2, 1}) {
+			t.Errorf("Remap left %+v behind", mapping)
+		}
+	}
+}
+
+func TestNATReplies(t *testing.T) {
+	// The first player is on the public subnet, the second on a private one
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.PublicSubnet = 1
+	})
+	public, private := room.players[0], room.players[1]
+
+	challenge, result := room.request(t, private)
+	if result.Translation == nil {
+		t.Fatalf("challenge to %s was not translated", challenge.DestIP)
+	}
+	source := result.Translation.PublicAddress()
+	if got := room.received(public, Translated); len(got) != 1 || !strings.Contains(string(got[0]), source) {
+		t.Errorf("destination was told %s, want %s", got, source)
+	}
+
+	tests := []struct {
+		name    string
+		replyTo string
+		wantErr string
+		correct bool
+	}{
+		{"reply to the private address", room.ip(private).String(), "NOT_TRANSLATED", false},
+		{"reply to another port", "192.168.1.254:1", "NOT_TRANSLATED", false},
+		{"reply to the translated address", source, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room.Answer(private, AnswerMessage{
+				Destination: challenge.DestIP,
+				Question:    challenge.Question,
+				Answer:      challenge.Answer,
+				ReplyTo:     tt.replyTo,
+			})
+
+			errs := room.failures(private)
+			if tt.wantErr != "" && (len(errs) == 0 || !strings.HasPrefix(errs[0], tt.wantErr)) {
+				t.Errorf("errors = %v, want %s", errs, tt.wantErr)
+			}
+			if got := room.result(challenge).Correct; got != tt.correct {
+				t.Errorf("Correct = %v, want %v", got, tt.correct)
+			}
+		})
+	}
+
+	if _, ok := room.Metadata.NAT.Lookup(source); ok {
+		t.Errorf("mapping %s outlived its challenge", source)
+	}
+}
+
+func TestNATExpiry(t *testing.T) {
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.PublicSubnet = 1
+		opts.ChallengeTTL = 1
+	})
+	challenge, result := room.request(t, room.players[1])
+
+	room.expire(result.Created.Add(2 * time.Second))
+	if !room.result(challenge).Expired {
+		t.Fatal("challenge did not expire")
+	}
+	if len(room.Metadata.NAT.Mappings) != 0 {
+		t.Errorf("expired challenge left mappings %+v", room.Metadata.NAT.Mappings)
+	}
+
+	// Replies to the expired mapping are dropped by the gateway
+	err := room.Forwarded(result, room.ip(room.players[1]), result.Translation.PublicAddress())
+	if err == nil || !strings.HasPrefix(err.Error(), "NO_MAPPING") {
+		t.Errorf("Forwarded() = %v, want NO_MAPPING", err)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrWrongState = errors.New("options can only be changed while the room is waiting")
+
+// RoomOptions are the host-configurable rules of a room
+//
+// Options can only be changed while the room is in the Waiting state
+type RoomOptions struct {
+	// The subnet acting as the "public" side of the NAT gateway (0 disables NAT)
+	//
+	// Every other subnet is treated as private
+	PublicSubnet int `json:"public_subnet"`
+
+	// Challenges hand out hostnames instead of IP addresses
+	DNS bool `json:"dns"`
+
+	// How long (in seconds) a resolved record may be cached (0 never expires)
+	DNSTTL int `json:"dns_ttl"`
+
+	// Each subnet gets its own zone (blue-tiger.subnet2.classnet)
+	DNSZonePerSubnet bool `json:"dns_zone_per_subnet"`
+
+	// A student acting as the resolver (nil means the server resolves)
+	DNSResolver *IP `json:"dns_resolver,omitempty"`
+
+	// The probability (0-1) that a challenge is sent to the sender's subnet broadcast address
+	BroadcastChance float64 `json:"broadcast_chance"`
+
+	// The probability (0-1) that a challenge is sent to one of the multicast groups
+	MulticastChance float64 `json:"multicast_chance"`
+
+	// The multicast groups defined by the host
+	MulticastGroups []MulticastGroup `json:"multicast_groups"`
+
+	// How many recipients of a broadcast or multicast challenge must reply
+	GroupDelivery Delivery `json:"group_delivery"`
+
+	// Challenges must be exchanged over a simulated TCP connection
+	TCP bool `json:"tcp"`
+
+	// The MTU (in characters) of each subnet. Questions longer than the path MTU must be fragmented
+	MTU map[int]int `json:"mtu"`
+
+	// The alphabet Q/A tables are built from (see Alphabets)
+	Alphabet string `json:"alphabet"`
+
+	// The number of players whose table holds each question in the room's bank
+	QARedundancy int `json:"qa_redundancy"`
+
+	// The probability (0-1) that a challenge's question may also be in the sender's own table
+	QAOverlap float64 `json:"qa_overlap"`
+
+	// The instructor-authored question bank to use instead of random symbols ("" for none)
+	QuestionBank string `json:"question_bank"`
+
+	// The networking question generators to use instead of random symbols (see QuestionGenerators)
+	Generators []string `json:"generators"`
+
+	// The cipher questions are encrypted with ("", "caesar", "vigenere" or "rsa")
+	//
+	// Only the destination knows the key, so it has to decrypt the question before looking it up
+	Cipher string `json:"cipher"`
+
+	// The probability (0-1) that a challenge must be relayed through a chain of hosts
+	ChainChance float64 `json:"chain_chance"`
+
+	// The number of hosts in a chain (A -> B -> C is 2)
+	ChainLength int `json:"chain_length"`
+
+	// How long (in seconds) a challenge may go unanswered before it expires (0 never expires)
+	ChallengeTTL int `json:"challenge_ttl"`
+
+	// The most challenges a student can have outstanding at once (0 is unlimited)
+	MaxOutstanding int `json:"max_outstanding"`
+
+	// How challenge destinations are chosen ("random", "balanced" or "subnet-balanced")
+	DestinationPolicy string `json:"destination_policy"`
+
+	// How challenges are scored
+	Scoring ScoringRules `json:"scoring"`
+
+	// Priority packets, shields and broadcast storms
+	PowerUps PowerUpRules `json:"power_ups"`
+
+	// The achievements players can earn
+	Achievements []Achievement `json:"achievements"`
+
+	// How challenges adapt to each player's answer rate
+	Difficulty DifficultyRules `json:"difficulty"`
+
+	// Every subnet is a team, and scores are totalled per subnet
+	TeamMode bool `json:"team_mode"`
+
+	// Where team mode challenges may be sent ("any", "intra" or "inter")
+	TeamScope string `json:"team_scope"`
+
+	// How newcomers join once the game has started ("closed", "auto" or "approve")
+	LateJoin string `json:"late_join"`
+
+	// How players change subnet once the game has started ("closed" or "approve")
+	SubnetChanges string `json:"subnet_changes"`
+
+	// The number of correct answers the whole class needs to finish the game (0 for no goal)
+	CoopGoal int `json:"coop_goal"`
+
+	// The number of rounds in a tournament (0 or 1 is a single game)
+	Rounds int `json:"rounds"`
+
+	// Players are moved to a random subnet at the start of every round
+	ShuffleSubnets bool `json:"shuffle_subnets"`
+
+	// Option changes for each round, applied on top of the previous round's options
+	//
+	// The first entry is for round 2, because round 1 uses the room's options. null leaves a round unchanged
+	RoundRules []json.RawMessage `json:"round_rules"`
+}
+
+// DefaultRoomOptions returns the options used by newly created rooms
+func DefaultRoomOptions() RoomOptions {
+	return RoomOptions{
+		DNSTTL:            60,
+		MulticastGroups:   []MulticastGroup{},
+		GroupDelivery:     DeliverAny,
+		MTU:               map[int]int{},
+		Alphabet:          DefaultAlphabet,
+		QARedundancy:      1,
+		Generators:        []string{},
+		ChainLength:       2,
+		DestinationPolicy: RandomPolicy,
+		Scoring:           DefaultScoringRules(),
+		Difficulty:        DefaultDifficultyRules(),
+		Achievements:      DefaultAchievements(),
+		PowerUps:          DefaultPowerUpRules(),
+		TeamScope:         AnyScope,
+		RoundRules:        []json.RawMessage{},
+		LateJoin:          ClosedPolicy,
+		SubnetChanges:     ClosedPolicy,
+	}
+}
+
+// clone returns a deep copy of the options
+func (opts RoomOptions) clone() (RoomOptions, error) {
+	// Round trip through JSON so maps, slices and pointers aren't shared with the original
+	var clone RoomOptions
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return clone, err
+	}
+	err = json.Unmarshal(data, &clone)
+	return clone, err
+}
+
+// Validate checks that the options make sense for a room with numSubnets subnets
+func (opts RoomOptions) Validate(numSubnets int) error {
+	if opts.PublicSubnet < 0 || opts.PublicSubnet > numSubnets {
+		return fmt.Errorf("public subnet %d does not exist. Expected 0 <= subnet <= %d", opts.PublicSubnet, numSubnets)
+	}
+	if opts.DNSTTL < 0 {
+		return fmt.Errorf("dns ttl must not be negative (got %d)", opts.DNSTTL)
+	}
+	if opts.DNSResolver != nil && (opts.DNSResolver.Subnet <= 0 || opts.DNSResolver.Subnet > numSubnets) {
+		return fmt.Errorf("dns resolver %s is not in any subnet", opts.DNSResolver)
+	}
+	if opts.BroadcastChance < 0 || opts.MulticastChance < 0 || opts.BroadcastChance+opts.MulticastChance > 1 {
+		return fmt.Errorf("broadcast and multicast chances must be between 0 and 1 (got %v and %v)", opts.BroadcastChance, opts.MulticastChance)
+	}
+	for _, group := range opts.MulticastGroups {
+		if err := group.Validate(); err != nil {
+			return err
+		}
+	}
+	for subnet, mtu := range opts.MTU {
+		if subnet <= 0 || subnet > numSubnets || mtu < 0 {
+			return fmt.Errorf("invalid mtu %d for subnet %d", mtu, subnet)
+		}
+	}
+	if _, ok := Alphabets[opts.Alphabet]; !ok {
+		return fmt.Errorf("unknown alphabet %q", opts.Alphabet)
+	}
+	for _, generator := range opts.Generators {
+		if _, ok := QuestionGenerators[generator]; !ok {
+			return fmt.Errorf("unknown question generator %q", generator)
+		}
+	}
+	if err := opts.validateCipher(); err != nil {
+		return err
+	}
+	if err := opts.Scoring.Validate(); err != nil {
+		return err
+	}
+	if err := opts.Difficulty.Validate(); err != nil {
+		return err
+	}
+	if err := opts.validateAchievements(); err != nil {
+		return err
+	}
+	if err := opts.PowerUps.Validate(); err != nil {
+		return err
+	}
+	switch opts.TeamScope {
+	case AnyScope, IntraScope, InterScope:
+	default:
+		return fmt.Errorf("unknown team scope %q", opts.TeamScope)
+	}
+	if err := opts.validateRounds(numSubnets); err != nil {
+		return err
+	}
+	switch opts.LateJoin {
+	case ClosedPolicy, AutoPolicy, ApprovePolicy:
+	default:
+		return fmt.Errorf("unknown late join policy %q", opts.LateJoin)
+	}
+	switch opts.SubnetChanges {
+	case ClosedPolicy, ApprovePolicy:
+	default:
+		return fmt.Errorf("unknown subnet change policy %q", opts.SubnetChanges)
+	}
+	if opts.CoopGoal < 0 {
+		return fmt.Errorf("co-op goal must not be negative (got %d)", opts.CoopGoal)
+	}
+	if opts.MaxOutstanding < 0 {
+		return fmt.Errorf("max outstanding must not be negative (got %d)", opts.MaxOutstanding)
+	}
+	switch opts.DestinationPolicy {
+	case RandomPolicy, BalancedPolicy, SubnetBalancedPolicy:
+	default:
+		return fmt.Errorf("unknown destination policy %q", opts.DestinationPolicy)
+	}
+	if opts.ChallengeTTL < 0 {
+		return fmt.Errorf("challenge ttl must not be negative (got %d)", opts.ChallengeTTL)
+	}
+	if opts.ChainChance < 0 || opts.ChainChance > 1 {
+		return fmt.Errorf("chain chance must be between 0 and 1 (got %v)", opts.ChainChance)
+	}
+	if opts.ChainLength < 2 {
+		return fmt.Errorf("chains need at least 2 hosts (got %d)", opts.ChainLength)
+	}
+	if opts.QARedundancy < 1 {
+		return fmt.Errorf("qa redundancy must be at least 1 (got %d)", opts.QARedundancy)
+	}
+	if opts.QAOverlap < 0 || opts.QAOverlap > 1 {
+		return fmt.Errorf("qa overlap must be between 0 and 1 (got %v)", opts.QAOverlap)
+	}
+	if opts.GroupDelivery != DeliverAny && opts.GroupDelivery != DeliverAll {
+		return fmt.Errorf("group delivery must be %q or %q (got %q)", DeliverAny, DeliverAll, opts.GroupDelivery)
+	}
+	return nil
+}
+
+// SetOptions validates and applies new options to the room
+func (room *Room) SetOptions(opts RoomOptions) error {
+	room.Lock()
+	if room.State.State != Waiting {
+		room.Unlock()
+		return ErrWrongState
+	}
+
+	regenerate, rekey, err := room.setOptionsLocked(opts)
+	room.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Options can change the room's Metadata, so it needs to be rebroadcasted
+	room.BroadcastMetadata()
+	room.BroadcastGameState()
+	if regenerate || rekey {
+		room.BroadcastUserdata()
+	}
+	return nil
+}
+
+// setOptionsLocked validates and applies new options to the room, returning whether
+// the Q/A tables were regenerated and the keys were replaced
+//
+// Nothing is changed if the options are invalid. The caller must hold the room's lock
+func (room *Room) setOptionsLocked(opts RoomOptions) (regenerate, rekey bool, err error) {
+	if err := opts.Validate(room.Metadata.NumSubnets); err != nil {
+		return false, false, err
+	}
+
+	// Changing how questions are generated invalidates every Q/A table
+	regenerate = opts.Alphabet != room.Options.Alphabet ||
+		opts.QARedundancy != room.Options.QARedundancy ||
+		opts.QuestionBank != room.Options.QuestionBank ||
+		strings.Join(opts.Generators, ",") != strings.Join(room.Options.Generators, ",")
+
+	// Load the question bank from the library
+	if opts.QuestionBank != "" && regenerate {
+		bank, err := LoadQuestionBank(opts.QuestionBank)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to load question bank %q: %w", opts.QuestionBank, err)
+		}
+		room.questionBank = &bank
+	} else if opts.QuestionBank == "" {
+		room.questionBank = nil
+	}
+
+	rekey = opts.Cipher != room.Options.Cipher
+	room.Options = opts
+	if regenerate {
+		room.RegenerateQATables()
+	}
+	if rekey {
+		for name := range room.QATables {
+			room.AssignKey(name)
+		}
+	}
+
+	// Enable (or disable) the NAT gateway
+	if opts.PublicSubnet != 0 {
+		room.Metadata.NAT = NewNATTable(opts.PublicSubnet)
+	} else {
+		room.Metadata.NAT = nil
+	}
+
+	// The co-op goal and team mode change the shape of the scoreboard
+	room.State.Goal = opts.CoopGoal
+	room.UpdateScoreboard()
+	return regenerate, rekey, nil
+}
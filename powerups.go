@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// How often the rules engine checks the room's schedule
+const rulesInterval = time.Second
+
+// PowerUpRules configure the room's game-rules engine
+type PowerUpRules struct {
+	// The probability (0-1) that a challenge is a priority packet
+	PriorityChance float64 `json:"priority_chance"`
+
+	// How many times the usual points a priority packet is worth
+	PriorityMultiplier int `json:"priority_multiplier"`
+
+	// Players earn a shield for every ShieldEvery correct answers (0 disables shields)
+	ShieldEvery int `json:"shield_every"`
+
+	// The most shields a player can hold at once
+	MaxShields int `json:"max_shields"`
+
+	// How long (in seconds) between broadcast storms (0 disables storms)
+	StormInterval int `json:"storm_interval"`
+
+	// How long (in seconds) a broadcast storm lasts
+	StormDuration int `json:"storm_duration"`
+}
+
+// DefaultPowerUpRules returns the power-up rules used by newly created rooms
+func DefaultPowerUpRules() PowerUpRules {
+	return PowerUpRules{
+		PriorityMultiplier: 3,
+		MaxShields:         3,
+		StormDuration:      30,
+	}
+}
+
+// Validate checks that the rules make sense
+func (rules PowerUpRules) Validate() error {
+	if rules.PriorityChance < 0 || rules.PriorityChance > 1 {
+		return fmt.Errorf("priority chance must be between 0 and 1 (got %v)", rules.PriorityChance)
+	}
+	if rules.PriorityMultiplier < 1 {
+		return fmt.Errorf("priority multiplier must be at least 1 (got %d)", rules.PriorityMultiplier)
+	}
+	if rules.ShieldEvery < 0 || rules.MaxShields < 0 {
+		return fmt.Errorf("shields must not be negative")
+	}
+	if rules.StormInterval < 0 || rules.StormDuration < 0 || (rules.StormInterval > 0 && rules.StormDuration == 0) {
+		return fmt.Errorf("storms need a positive interval and duration")
+	}
+	return nil
+}
+
+// RulesEngine schedules the room's power-ups and special events
+type RulesEngine struct {
+	// When the current broadcast storm ends (zero if there is no storm)
+	stormUntil time.Time
+
+	// When the next broadcast storm starts
+	nextStorm time.Time
+}
+
+// Storming returns true while a broadcast storm is raging
+//
+// The caller must hold the room's lock
+func (room *Room) Storming() bool {
+	return time.Now().Before(room.rules.stormUntil)
+}
+
+// Copies returns how many challenges a single request creates (doubled during a storm)
+//
+// The caller must hold the room's lock
+func (room *Room) Copies() int {
+	if room.Storming() {
+		return 2
+	}
+	return 1
+}
+
+// ApplyPowerUps decides if a new challenge is special
+//
+// The caller must hold the room's lock
+func (room *Room) ApplyPowerUps(result *ChallengeResult) {
+	if rand.Float64() < room.Options.PowerUps.PriorityChance {
+		result.Priority = true
+	}
+}
+
+// Shields returns the number of shields a player can use
+//
+// The caller must hold the room's lock
+func (room *Room) Shields(name Name) int {
+	rules := room.Options.PowerUps
+	if rules.ShieldEvery == 0 {
+		return 0
+	}
+
+	earned := room.Stats(name).Correct / rules.ShieldEvery
+	return max(min(earned-room.ShieldsUsed[name], rules.MaxShields), 0)
+}
+
+// RunRules drives the rules engine's schedule for as long as the room exists
+func (room *Room) RunRules() {
+	ticker := time.NewTicker(rulesInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		room.scheduleStorm(now)
+	}
+}
+
+// scheduleStorm starts and ends broadcast storms, telling every client
+func (room *Room) scheduleStorm(now time.Time) {
+	room.Lock()
+	interval := time.Duration(room.Options.PowerUps.StormInterval) * time.Second
+	duration := time.Duration(room.Options.PowerUps.StormDuration) * time.Second
+
+	var msg *Message
+	switch {
+	case room.State.State != Running || interval == 0:
+		room.rules.nextStorm = time.Time{}
+
+		// A storm doesn't outlast the game
+		if !room.State.StormUntil.IsZero() {
+			room.rules.stormUntil = time.Time{}
+			room.State.StormUntil = time.Time{}
+			storm := NewStormMessage(false, time.Time{})
+			msg = &storm
+		}
+	case room.rules.nextStorm.IsZero():
+		room.rules.nextStorm = now.Add(interval)
+	case !room.State.StormUntil.IsZero() && !room.Storming():
+		// The storm is over
+		room.State.StormUntil = time.Time{}
+		storm := NewStormMessage(false, time.Time{})
+		msg = &storm
+	case now.After(room.rules.nextStorm):
+		room.rules.stormUntil = now.Add(duration)
+		room.rules.nextStorm = room.rules.stormUntil.Add(interval)
+		room.State.StormUntil = room.rules.stormUntil
+		storm := NewStormMessage(true, room.rules.stormUntil)
+		msg = &storm
+	}
+	room.Unlock()
+
+	if msg != nil {
+		room.Broadcast(*msg)
+		room.BroadcastGameState()
+	}
+}
+
+// UseShield is called to handle a Shield message, refusing a challenge sent to the client
+func (room *Room) UseShield(client *Client, msg ShieldMessage) {
+	room.Lock()
+	if room.State.State != Running {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Shields can only be used while the game is running (state: %d)", room.State.State)))
+		room.Unlock()
+		return
+	}
+
+	if room.Shields(client.Name) == 0 {
+		_ = client.Send(NewError("NO_SHIELDS: You don't have any shields"))
+		room.Unlock()
+		return
+	}
+
+	ip, ok := room.address(client)
+	if !ok {
+		room.Unlock()
+		return
+	}
+	challenge, result, ok := room.FindChallenge(ip.String(), msg.Source, msg.Question)
+	if !ok || !result.Outstanding() {
+		_ = client.Send(NewError(fmt.Sprintf("NO_CHALLENGE: %s is not waiting on you", msg.Source)))
+		room.Unlock()
+		return
+	}
+
+	// The challenge is refused, costing the sender nothing
+	result.Expired = true
+	result.Refused = true
+	room.record(challenge, result)
+	room.ShieldsUsed[client.Name]++
+	released := room.releaseMapping(result)
+
+	var sender *Client
+	if source, err := ParseIP(challenge.SourceIP); err == nil {
+		sender = room.ClientByIP(source)
+	}
+	room.Unlock()
+
+	if sender != nil {
+		_ = sender.Send(NewExpiredMessage(challenge.DestIP, result.Transmitted(challenge)))
+	}
+	room.SendUserdata(client)
+	if released {
+		room.BroadcastMetadata()
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPowerUpRulesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   PowerUpRules
+		wantErr bool
+	}{
+		{"default", DefaultPowerUpRules(), false},
+		{"chance above 1", PowerUpRules{PriorityChance: 1.5, PriorityMultiplier: 1}, true},
+		{"no multiplier", PowerUpRules{}, true},
+		{"negative shields", PowerUpRules{PriorityMultiplier: 1, ShieldEvery: -1}, true},
+		{"storms without a duration", PowerUpRules{PriorityMultiplier: 1, StormInterval: 60}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleStorm(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		state RoomState
+		// The storm in progress (zero for none) and when the next one is due
+		stormUntil, nextStorm time.Time
+		storming              bool
+		// The storm message broadcast, if any
+		broadcast *bool
+	}{
+		{"first tick schedules a storm", Running, time.Time{}, time.Time{}, false, nil},
+		{"storm starts", Running, time.Time{}, now.Add(-time.Second), true, ptr(true)},
+		{"storm rages on", Running, now.Add(time.Minute), now.Add(time.Hour), true, nil},
+		{"storm is over", Running, now.Add(-time.Second), now.Add(time.Hour), false, ptr(false)},
+		{"game stops mid-storm", Stopping, now.Add(time.Minute), now.Add(time.Hour), false, ptr(false)},
+		{"game is stopped", Stopped, time.Time{}, time.Time{}, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.PowerUps.StormInterval = 60
+			})
+			room.State.State = tt.state
+			room.State.StormUntil = tt.stormUntil
+			room.rules = RulesEngine{stormUntil: tt.stormUntil, nextStorm: tt.nextStorm}
+
+			room.scheduleStorm(now)
+
+			if got := room.Storming(); got != tt.storming {
+				t.Errorf("Storming = %v, want %v", got, tt.storming)
+			}
+			if got := !room.State.StormUntil.IsZero(); got != tt.storming {
+				t.Errorf("StormUntil = %v, want a storm: %v", room.State.StormUntil, tt.storming)
+			}
+
+			storms := room.received(room.players[0], Storm)
+			if tt.broadcast == nil {
+				if len(storms) != 0 {
+					t.Errorf("%d storm messages broadcast, want none", len(storms))
+				}
+				return
+			}
+			if len(storms) != 1 {
+				t.Fatalf("%d storm messages broadcast, want 1", len(storms))
+			}
+			var storm StormMessage
+			_ = json.Unmarshal(storms[0], &storm)
+			if storm.Active != *tt.broadcast {
+				t.Errorf("storm message Active = %v, want %v", storm.Active, *tt.broadcast)
+			}
+		})
+	}
+}
+
+func TestShields(t *testing.T) {
+	tests := []struct {
+		name    string
+		every   int
+		correct int
+		used    int
+		want    int
+	}{
+		{"shields disabled", 0, 10, 0, 0},
+		{"none earned", 2, 1, 0, 0},
+		{"one earned", 2, 2, 0, 1},
+		{"capped", 2, 10, 0, 3},
+		{"used", 2, 4, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.PowerUps.ShieldEvery = tt.every
+			})
+			player := room.players[0]
+			for i := 0; i < tt.correct; i++ {
+				challenge := Challenge{ID: i, SourceIP: room.ip(player).String(), DestIP: room.ip(room.players[1]).String()}
+				room.record(challenge, ChallengeResult{Created: time.Now(), Answered: time.Now(), Correct: true})
+			}
+			room.ShieldsUsed[player.Name] = tt.used
+
+			if got := room.Shields(player.Name); got != tt.want {
+				t.Errorf("Shields = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseShield(t *testing.T) {
+	tests := []struct {
+		name    string
+		shields bool
+		state   RoomState
+		want    string
+	}{
+		{"refuses the challenge", true, Running, ""},
+		{"no shields", false, Running, "NO_SHIELDS"},
+		{"game is over", true, Stopped, "WRONG_STATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.PowerUps.ShieldEvery = 1
+			})
+			sender, dest := room.players[0], room.players[1]
+			if tt.shields {
+				earned := Challenge{ID: 100, SourceIP: room.ip(dest).String(), DestIP: room.ip(sender).String()}
+				room.record(earned, ChallengeResult{Created: time.Now(), Answered: time.Now(), Correct: true})
+			}
+			challenge, _ := room.request(t, sender)
+			room.discard()
+			room.State.State = tt.state
+
+			room.UseShield(dest, ShieldMessage{Source: challenge.SourceIP, Question: challenge.Question})
+
+			errs := room.failures(dest)
+			if tt.want != "" {
+				if len(errs) != 1 || !strings.HasPrefix(errs[0], tt.want) {
+					t.Errorf("errors = %v, want %q", errs, tt.want)
+				}
+				if room.result(challenge).Refused {
+					t.Errorf("the challenge was refused")
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Fatalf("errors = %v", errs)
+			}
+
+			result := room.result(challenge)
+			if !result.Refused || !result.Expired {
+				t.Errorf("result = %+v, want a refused challenge", result)
+			}
+			if len(room.received(sender, Expired)) != 1 {
+				t.Errorf("the sender wasn't told the challenge expired")
+			}
+			if got := room.Shields(dest.Name); got != 0 {
+				t.Errorf("%d shields left, want 0", got)
+			}
+			if got := room.Scores()[sender.Name]; got != 0 {
+				t.Errorf("refused challenge cost the sender %d points", got)
+			}
+		})
+	}
+}
+
+func TestShieldedRecordsAreKept(t *testing.T) {
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.PowerUps.ShieldEvery = 1
+		opts.PowerUps.MaxShields = 10
+	})
+	sender, dest := room.players[0], room.players[1]
+	for i := 0; i < 3; i++ {
+		earned := Challenge{ID: 100 + i, SourceIP: room.ip(dest).String(), DestIP: room.ip(sender).String()}
+		room.record(earned, ChallengeResult{Created: time.Now(), Answered: time.Now(), Correct: true})
+	}
+
+	// The same question can be sent, and shielded, again without replacing the earlier record
+	var shielded []Challenge
+	for i := 0; i < 2; i++ {
+		challenge := Challenge{ID: i + 1, SourceIP: room.ip(sender).String(), DestIP: room.ip(dest).String(), Question: "QQQQ", Answer: "AAAA"}
+		room.record(challenge, ChallengeResult{Created: time.Now()})
+		room.UseShield(dest, ShieldMessage{Source: challenge.SourceIP, Question: challenge.Question})
+		shielded = append(shielded, challenge)
+	}
+
+	tests := []struct {
+		name      string
+		challenge Challenge
+	}{
+		{"first shielded challenge", shielded[0]},
+		{"second shielded challenge", shielded[1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := room.Challenges[tt.challenge]
+			if !ok || !result.Refused {
+				t.Errorf("result = %+v (kept: %v), want a refused challenge", result, ok)
+			}
+		})
+	}
+	if used := room.ShieldsUsed[dest.Name]; used != 2 {
+		t.Errorf("%d shields used, want 2", used)
+	}
+}
+
+// ptr returns a pointer to v
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -1,13 +1,22 @@
 package main
 
-import "math/rand"
+import (
+	"math/rand"
+	"sort"
+)
 
 var symbols = "0123456789ABCDEF"
 
+// The number of new entries each player brings to the room's question bank
+const qaTableSize = 16
+
+// The number of attempts at generating a distinguishable entry before entries get longer
+const qaAttempts = 100
+
 // Question-Answer table
 type QATable map[string]string
 
-// Table are made up of "symbols"
+// Room codes are made up of 4 hex "symbols"
 func randomSymbol() string {
 	symbol := make([]byte, 4)
 	for i := range symbol {
@@ -16,26 +25,247 @@ func randomSymbol() string {
 	return string(symbol)
 }
 
-func NewQATable() QATable {
-	table := make(map[string]string)
+// Returns true if entry can't be confused with any of the table's questions
+func (table QATable) distinguishable(alphabet Alphabet, entry string) bool {
+	for question := range table {
+		if !alphabet.Distinguishable(question, entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// newQAEntry generates a question that can't be confused with any question in the room's bank
+//
+// When the room uses an instructor-authored bank, the next unused entry is returned
+// instead, and when the room uses question generators a new generated question is
+// returned. Returns false once no new question can be found.
+func (room *Room) newQAEntry() (string, string, bool) {
+	if room.questionBank != nil {
+		for _, entry := range room.questionBank.Entries {
+			if _, ok := room.QABank[entry.Question]; !ok {
+				return entry.Question, entry.Answer, true
+			}
+		}
+		return "", "", false
+	}
+
+	// Generated networking questions are unique, but some generators only know a few questions
+	if len(room.Options.Generators) > 0 {
+		for attempt := 0; attempt < qaAttempts; attempt++ {
+			name := room.Options.Generators[rand.Intn(len(room.Options.Generators))]
+			question, answer := QuestionGenerators[name]()
+			if _, ok := room.QABank[question]; !ok {
+				room.generatedBy[question] = name
+				return question, answer, true
+			}
+		}
+		return "", "", false
+	}
+
+	question := room.newSymbol()
+
+	// Generate a random answer
+	var answer string
+	for {
+		answer = Alphabets[room.Options.Alphabet].Random()
+		if answer != question {
+			break
+		}
+	}
+
+	return question, answer, true
+}
+
+// newSymbol generates an entry from the room's alphabet that can't be confused with any question in the bank
+//
+// Small alphabets run out of distinguishable entries, so entries grow a symbol
+// longer whenever too many attempts fail
+func (room *Room) newSymbol() string {
+	alphabet := Alphabets[room.Options.Alphabet]
+	length := alphabet.Length
+
+	var symbol string
+	for attempt := 0; ; attempt++ {
+		if attempt == qaAttempts {
+			attempt = 0
+			length++
+		}
+
+		symbol = alphabet.RandomN(length)
+		if room.QABank.distinguishable(alphabet, symbol) {
+			return symbol
+		}
+	}
+}
+
+// holders returns the players whose table contains the question
+func (room *Room) holders(question string) []Name {
+	var names []Name
+	for name, table := range room.QATables {
+		if _, ok := table[question]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AssignQATable builds a player's Q/A table from the room-wide question bank.
+//
+// The player brings new questions to the bank until their table holds qaTableSize
+// questions, each of which is copied
+// into the tables of other players until it is held by QARedundancy players. The
+// new player also picks up any question that is still held by too few players.
+//
+// Returns the other players whose tables grew, so they can be sent their new questions
+func (room *Room) AssignQATable(name Name) []Name {
+	table := make(QATable)
+	room.QATables[name] = table
+	redundancy := room.Options.QARedundancy
+
+	// Top up under-replicated questions
+	for question, answer := range room.QABank {
+		if _, ok := table[question]; !ok && len(room.holders(question)) < redundancy {
+			table[question] = answer
+		}
+	}
+
+	// Everyone else, in a random order
+	others := make([]Name, 0, len(room.QATables))
+	for other := range room.QATables {
+		if other != name {
+			others = append(others, other)
+		}
+	}
+
+	var shared []Name
+	for len(table) < qaTableSize {
+		question, answer, ok := room.newQAEntry()
+		if !ok {
+			// Every possible question is in use, share the least held ones instead
+			room.shareQAEntries(table)
+			return shared
+		}
+		room.QABank[question] = answer
+		table[question] = answer
+
+		rand.Shuffle(len(others), func(i, j int) {
+			others[i], others[j] = others[j], others[i]
+		})
+		for _, other := range others[:min(redundancy-1, len(others))] {
+			room.QATables[other][question] = answer
+			if !containsName(shared, other) {
+				shared = append(shared, other)
+			}
+		}
+	}
+	return shared
+}
+
+// shareQAEntries fills a table up to qaTableSize with the bank's least held questions
+func (room *Room) shareQAEntries(table QATable) {
+	questions := make([]string, 0, len(room.QABank))
+	held := make(map[string]int)
+	for question := range room.QABank {
+		if _, ok := table[question]; !ok {
+			questions = append(questions, question)
+			held[question] = len(room.holders(question))
+		}
+	}
+
+	rand.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+	sort.SliceStable(questions, func(i, j int) bool {
+		return held[questions[i]] < held[questions[j]]
+	})
+
+	for _, question := range questions[:min(qaTableSize-len(table), len(questions))] {
+		table[question] = room.QABank[question]
+	}
+}
+
+// RegenerateQATables throws away the question bank and rebuilds every player's table
+func (room *Room) RegenerateQATables() {
+	names := make([]Name, 0, len(room.QATables))
+	for name := range room.QATables {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].String() < names[j].String()
+	})
 
-	// Generate 16 random symbols
-	for i := 0; i < 16; i++ {
-		// Generate a symbol that isn't already in the table
-		var symbol string
-		for {
-			symbol = randomSymbol()
-			if _, ok := table[symbol]; !ok {
-				break
+	room.QABank = make(QATable)
+	room.QATables = make(map[Name]QATable)
+	room.generatedBy = make(map[string]string)
+	for _, name := range names {
+		room.AssignQATable(name)
+	}
+}
+
+// asked returns the questions source has already asked destination, unless they expired
+func (room *Room) asked(source IP, destination string) map[string]bool {
+	asked := make(map[string]bool)
+	for challenge, result := range room.Challenges {
+		if challenge.DestIP == destination && challenge.SourceIP == source.String() && !result.Expired {
+			asked[challenge.Question] = true
+		}
+	}
+	return asked
+}
+
+// ChooseQuestion picks the question for a new challenge sent from source to targets.
+//
+// The question always comes from a target's table, so the destination is able to
+// look up the answer. Unless the room allows overlap, questions the sender could
+// answer from their own table are avoided.
+//
+// Returns the challenge and the targets that hold its question.
+func (room *Room) ChooseQuestion(source Name, sourceIP IP, destination string, targets []IP) (Challenge, []IP, bool) {
+	asked := room.asked(sourceIP, destination)
+
+	// Every question any target can answer, that hasn't already been asked
+	var candidates, own []Challenge
+	for _, target := range targets {
+		for question, answer := range room.QATables[room.Metadata.Subnets[target.Subnet][target.Host]] {
+			challenge := Challenge{
+				DestIP:   destination,
+				SourceIP: sourceIP.String(),
+				Question: question,
+				Answer:   answer,
+			}
+			if asked[question] {
+				continue
+			}
+
+			if _, ok := room.QATables[source][question]; ok {
+				own = append(own, challenge)
+			} else {
+				candidates = append(candidates, challenge)
 			}
 		}
+	}
+
+	if len(candidates) == 0 || rand.Float64() < room.Options.QAOverlap {
+		candidates = append(candidates, own...)
+	}
+	if len(candidates) == 0 {
+		return Challenge{}, nil, false
+	}
 
-		// Generate a random question
-		question := randomSymbol()
+	// Fast players get the longest questions
+	if room.Harder(sourceIP, LongQuestionLevel) {
+		candidates = longest(candidates)
+	}
+	challenge := candidates[rand.Intn(len(candidates))]
 
-		// Add the symbol and question to the table
-		table[symbol] = question
+	// Only the targets that hold the question are able to answer it
+	var holders []IP
+	for _, target := range targets {
+		if _, ok := room.QATables[room.Metadata.Subnets[target.Subnet][target.Host]][challenge.Question]; ok {
+			holders = append(holders, target)
+		}
 	}
 
-	return table
+	return challenge, holders, true
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAssignQATable(t *testing.T) {
+	tests := []struct {
+		name       string
+		players    int
+		redundancy int
+	}{
+		{"single player", 1, 2},
+		{"no redundancy", 4, 1},
+		{"every question held twice", 6, 2},
+		{"more redundancy than players", 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, tt.players, func(opts *RoomOptions) {
+				opts.QARedundancy = tt.redundancy
+			})
+
+			// Players bring qaTableSize questions, and are handed copies of everyone else's
+			for name, table := range room.QATables {
+				if len(table) < qaTableSize {
+					t.Errorf("%s holds %d questions, want at least %d", name, len(table), qaTableSize)
+				}
+				for question, answer := range table {
+					if room.QABank[question] != answer {
+						t.Errorf("%s has %q -> %q, but the bank says %q", name, question, answer, room.QABank[question])
+					}
+				}
+			}
+
+			want := min(tt.redundancy, tt.players)
+			for question := range room.QABank {
+				if held := len(room.holders(question)); held < want {
+					t.Errorf("%q is held by %d players, want at least %d", question, held, want)
+				}
+			}
+
+			// Questions in the bank never look alike
+			alphabet := Alphabets[room.Options.Alphabet]
+			for a := range room.QABank {
+				for b := range room.QABank {
+					if a != b && !alphabet.Distinguishable(a, b) {
+						t.Errorf("%q and %q can be confused", a, b)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChooseQuestionAvoidsOwnTable(t *testing.T) {
+	room := newTestRoom(t, 4, func(opts *RoomOptions) {
+		opts.QARedundancy = 2
+		opts.QAOverlap = 0
+	})
+	sender, dest := room.players[0], room.players[1]
+
+	for i := 0; i < 20; i++ {
+		challenge, holders, ok := room.ChooseQuestion(sender.Name, room.ip(sender), room.ip(dest).String(), []IP{room.ip(dest)})
+		if !ok {
+			t.Fatal("no question was chosen")
+		}
+		if _, own := room.QATables[sender.Name][challenge.Question]; own && len(holders) > 0 {
+			// Only allowed once every one of the destination's questions is also the sender's
+			for question := range room.QATables[dest.Name] {
+				if _, ok := room.QATables[sender.Name][question]; !ok {
+					t.Fatalf("chose %q from the sender's own table while %q was available", challenge.Question, question)
+				}
+			}
+		}
+		if _, ok := room.QATables[dest.Name][challenge.Question]; !ok {
+			t.Fatalf("%q is not in the destination's table", challenge.Question)
+		}
+	}
+}
+
+func TestNewPlayersShareTheirQuestions(t *testing.T) {
+	tests := []struct {
+		name       string
+		redundancy int
+		notified   bool
+	}{
+		{"no redundancy", 1, false},
+		{"questions held twice", 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 3, func(opts *RoomOptions) {
+				opts.QARedundancy = tt.redundancy
+			})
+			before := make(map[Name]int)
+			for _, player := range room.players {
+				before[player.Name] = len(room.QATables[player.Name])
+			}
+
+			newcomer := room.NewClient()
+			newcomer.send = make(chan []byte, 1024)
+
+			// Whoever was handed some of the newcomer's questions is sent their new table
+			anyGrew := false
+			for i, player := range room.players {
+				grew := len(room.QATables[player.Name]) > before[player.Name]
+				anyGrew = anyGrew || grew
+				if grew && !tt.notified {
+					t.Errorf("player %d was given questions without redundancy", i)
+				}
+				if got := len(room.received(player, Userdata)) > 0; got != grew {
+					t.Errorf("player %d sent userdata: %v, but their table grew: %v", i, got, grew)
+				}
+			}
+			if anyGrew != tt.notified {
+				t.Errorf("someone was handed the newcomer's questions: %v, want %v", anyGrew, tt.notified)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+// Responder tokens let the server know who actually looked up an answer.
+//
+// Every player has a few secret tokens, shown only to them. When replying to a
+// challenge they include one of their tokens, and the requester relays it along
+// with the answer. A token can only be redeemed once, after which it is replaced
+// by a new one. Having several live tokens means a player can reply to more than
+// one challenge at a time without the first redemption invalidating the others.
+
+// liveTokens is the number of responder tokens each player holds at once
+const liveTokens = 3
+
+// newToken returns a token that no player currently holds
+//
+// The caller must hold the room's lock
+func (room *Room) newToken() string {
+	for {
+		token := randomSymbol()
+		if _, i := room.tokenHolder(token); i < 0 {
+			return token
+		}
+	}
+}
+
+// tokenHolder returns the player holding a token and the token's position in their set
+//
+// The caller must hold the room's lock
+func (room *Room) tokenHolder(token string) (Name, int) {
+	if token == "" {
+		return Name{}, -1
+	}
+	for name, tokens := range room.Tokens {
+		for i, current := range tokens {
+			if NormalizeAnswer(current) == NormalizeAnswer(token) {
+				return name, i
+			}
+		}
+	}
+	return Name{}, -1
+}
+
+// AssignTokens gives a player a fresh set of responder tokens
+func (room *Room) AssignTokens(name Name) {
+	// The new set replaces the old one straight away, so tokens are unique within it too
+	tokens := make([]string, liveTokens)
+	room.Tokens[name] = tokens
+	for i := range tokens {
+		tokens[i] = room.newToken()
+	}
+}
+
+// TokenOwner returns the address of the player a responder token belongs to
+func (room *Room) TokenOwner(token string) (IP, bool) {
+	name, i := room.tokenHolder(token)
+	if i < 0 {
+		return IP{}, false
+	}
+	ip, ok := room.Metadata.IPAddresses[name]
+	return ip, ok
+}
+
+// RedeemToken credits the responder of a challenge if token is one of their live tokens.
+//
+// Returns the client whose token was used up (nil if the token was wrong)
+func (room *Room) RedeemToken(result *ChallengeResult, responder IP, token string) *Client {
+	name, ok := room.Metadata.Subnets[responder.Subnet][responder.Host]
+	if !ok {
+		return nil
+	}
+	holder, i := room.tokenHolder(token)
+	if i < 0 || holder != name {
+		return nil
+	}
+
+	if !containsIP(result.Confirmed, responder) {
+		result.Confirmed = append(result.Confirmed, responder)
+	}
+
+	// Tokens are single use, so they can't be replayed. The player's other tokens stay live
+	room.Tokens[name][i] = room.newToken()
+	return room.ClientByIP(responder)
+}
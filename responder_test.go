@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestAssignTokens(t *testing.T) {
+	room := newTestRoom(t, 8, nil)
+
+	seen := make(map[string]bool)
+	for _, client := range room.players {
+		tokens := room.Tokens[client.Name]
+		if len(tokens) != liveTokens {
+			t.Fatalf("%s has %d tokens, want %d", client.Name, len(tokens), liveTokens)
+		}
+		for _, token := range tokens {
+			if seen[token] {
+				t.Errorf("token %q was handed out twice", token)
+			}
+			seen[token] = true
+		}
+	}
+}
+
+func TestRedeemToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// Returns the token the sender relays
+		token    func(room *testRoom) string
+		credited bool
+	}{
+		{"first live token", func(room *testRoom) string { return room.Tokens[room.players[1].Name][0] }, true},
+		{"last live token", func(room *testRoom) string { return room.Tokens[room.players[1].Name][liveTokens-1] }, true},
+		{"surrounded by whitespace", func(room *testRoom) string { return "  " + room.Tokens[room.players[1].Name][1] + " " }, true},
+		{"someone else's token", func(room *testRoom) string { return room.Tokens[room.players[2].Name][0] }, false},
+		{"no token", func(room *testRoom) string { return "" }, false},
+		{"made up token", func(room *testRoom) string { return "????" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 3, nil)
+			responder := room.players[1]
+			before := append([]string(nil), room.Tokens[responder.Name]...)
+
+			token := tt.token(room)
+			var result ChallengeResult
+			credited := room.RedeemToken(&result, room.ip(responder), token)
+			if got := credited != nil; got != tt.credited || (got && credited != responder) {
+				t.Fatalf("RedeemToken = %v, want credited %v", credited, tt.credited)
+			}
+			if got := len(result.Confirmed) == 1; got != tt.credited {
+				t.Errorf("confirmed = %v, want %v", result.Confirmed, tt.credited)
+			}
+
+			// Only the redeemed token is replaced
+			changed := 0
+			for i, current := range room.Tokens[responder.Name] {
+				if current != before[i] {
+					changed++
+				}
+			}
+			if got := changed == 1; got != tt.credited || changed > 1 {
+				t.Errorf("%d tokens replaced, want a token replaced: %v", changed, tt.credited)
+			}
+
+			// A redeemed token can't be replayed
+			if tt.credited && room.RedeemToken(&result, room.ip(responder), token) != nil {
+				t.Errorf("token %q was redeemed twice", token)
+			}
+		})
+	}
+}
+
+func TestTokenOwner(t *testing.T) {
+	room := newTestRoom(t, 3, nil)
+
+	for _, client := range room.players {
+		for _, token := range room.Tokens[client.Name] {
+			if ip, ok := room.TokenOwner(token); !ok || ip != room.ip(client) {
+				t.Errorf("TokenOwner(%q) = %v, %v, want %v", token, ip, ok, room.ip(client))
+			}
+		}
+	}
+	if _, ok := room.TokenOwner(""); ok {
+		t.Errorf("the empty token has an owner")
+	}
+}
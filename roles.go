@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Role is a special part the host can give a player
+type Role string
+
+const (
+	// HonestRole is every player without a special role
+	HonestRole Role = ""
+	// SpooferRole sees other players' challenges and forges replies to them
+	SpooferRole Role = "spoofer"
+	// SnifferRole sees the traffic on its subnet and races to answer it
+	SnifferRole Role = "sniffer"
+)
+
+// Validate checks that the role exists
+func (role Role) Validate() error {
+	switch role {
+	case HonestRole, SpooferRole, SnifferRole:
+		return nil
+	}
+	return fmt.Errorf("unknown role %q", role)
+}
+
+// WithRole returns every client that has the role, other than the excluded hosts
+//
+// The caller must hold the room's lock
+func (room *Room) WithRole(role Role, exclude ...IP) []*Client {
+	var clients []*Client
+	for _, client := range room.Clients {
+		if room.Roles[client.Name] != role {
+			continue
+		}
+		if ip, ok := room.Metadata.IPAddresses[client.Name]; ok && containsIP(exclude, ip) {
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// SetRole gives the named player a role
+func (room *Room) SetRole(name string, role Role) error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+
+	room.Lock()
+	var target *Client
+	for _, client := range room.Clients {
+		if client.Name.String() == name {
+			target = client
+			break
+		}
+	}
+	if target == nil {
+		room.Unlock()
+		return ErrClientNotFound
+	}
+
+	if role == HonestRole {
+		delete(room.Roles, target.Name)
+	} else {
+		room.Roles[target.Name] = role
+	}
+	room.Unlock()
+
+	// Roles are secret, so only the player is told
+	room.SendUserdata(target)
+	return nil
+}
+
+// RoleHandler lets the host give a player a role
+// /room/{code}/roles/{name}?role=<role>&key=<key>
+func RoleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := vars["code"]
+	if code == "" {
+		http.Error(w, "Missing room code", http.StatusBadRequest)
+		return
+	}
+
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeHost(w, r, room) {
+		return
+	}
+
+	if err := room.SetRole(vars["name"], Role(r.FormValue("role"))); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRoleHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		host   bool
+		status int
+	}{
+		{"host gives a role", http.MethodPost, true, http.StatusOK},
+		{"player makes themselves a spoofer", http.MethodPost, false, http.StatusForbidden},
+		{"GET", http.MethodGet, true, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, nil)
+			player := room.players[0]
+
+			w := room.serve(t, RoleHandler, tt.method, tt.host, "role=spoofer", "", map[string]string{"name": player.Name.String()})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if spoofer := room.Roles[player.Name] == SpooferRole; spoofer != (tt.status == http.StatusOK) {
+				t.Errorf("player is a spoofer: %v", spoofer)
+			}
+		})
+	}
+}
@@ -26,9 +26,22 @@ type RoomMetadata struct {
 
 	// An index for player name to IP address (reverse index of SubnetPlayers)
 	IPAddresses map[Name]IP `json:"ip_addresses"`
+
+	// The NAT gateway's translation table (only present when NAT is enabled)
+	NAT *NATTable `json:"nat,omitempty"`
+
+	// Team names chosen by the host (subnets without a name are called by their address)
+	Teams map[int]string `json:"teams"`
+
+	// Subnets the host has taken down or partitioned
+	Network NetworkState `json:"network"`
 }
 
 type Challenge struct {
+	// The challenge's ID (unique per room). A question that expired can be asked
+	// again, and the new challenge must not replace the expired one's record
+	ID int `json:"id"`
+
 	// The challenge's destination IP address
 	DestIP string `json:"destIP"`
 
@@ -46,8 +59,95 @@ type ChallengeResult struct {
 	// If the question has been answered correctly
 	Correct bool `json:"correct"`
 
+	// If the challenge timed out before it was answered
+	Expired bool `json:"expired,omitempty"`
+
+	// The number of answers submitted, and how many of them were wrong
+	Attempts int `json:"attempts,omitempty"`
+	Wrong    int `json:"wrong,omitempty"`
+
+	// The time the question was answered correctly
+	Answered time.Time `json:"answered_at,omitempty"`
+
 	// The time the question was answered
 	Created time.Time `json:"answered"`
+
+	// The hosts a broadcast or multicast challenge was delivered to
+	Recipients []IP `json:"recipients,omitempty"`
+
+	// The recipients that have replied correctly
+	Responders []IP `json:"responders,omitempty"`
+
+	// The datagram carrying a fragmented question (0 if the question wasn't fragmented)
+	DatagramID int `json:"datagram_id,omitempty"`
+
+	// The gateway's translation of the sender's address (only when the challenge was source-NATed)
+	Translation *NATMapping `json:"translation,omitempty"`
+
+	// The encrypted question the sender transmits (only when questions are encrypted)
+	Ciphertext string `json:"ciphertext,omitempty"`
+
+	// The hosts a chain challenge is relayed through, in order (Challenge is a map key, so
+	// the hops live here)
+	Hops []IP `json:"hops,omitempty"`
+
+	// The question each hop receives
+	HopQuestions []string `json:"hop_questions,omitempty"`
+
+	// The number of hops that have relayed their token
+	Progress int `json:"progress,omitempty"`
+
+	// The responders whose token was relayed back with their answer
+	Confirmed []IP `json:"confirmed,omitempty"`
+
+	// Replies forged by spoofers
+	Forgeries []Forgery `json:"forgeries,omitempty"`
+
+	// The number of times the sender verified a reply's signature
+	Verifications int `json:"verifications,omitempty"`
+
+	// Answers submitted by sniffers that intercepted the challenge
+	Sniffs []SniffAttempt `json:"sniffs,omitempty"`
+
+	// The sender's difficulty level when the challenge was created
+	Level int `json:"level,omitempty"`
+
+	// Priority packets are worth more points
+	Priority bool `json:"priority,omitempty"`
+
+	// The destination refused the challenge with a shield
+	Refused bool `json:"refused,omitempty"`
+
+	// The prefix the answer may also be written with (only for generated hex and binary answers)
+	Radix string `json:"radix,omitempty"`
+}
+
+// Transmitted returns the question as it is actually sent over the network
+func (result ChallengeResult) Transmitted(challenge Challenge) string {
+	if result.Ciphertext != "" {
+		return result.Ciphertext
+	}
+	return challenge.Question
+}
+
+// FindChallenge finds the challenge sent from source to dest with the given question
+//
+// Encrypted challenges are also found by their ciphertext. When the question has been
+// asked more than once, the challenge that hasn't expired (or else the newest) is found
+func (room *Room) FindChallenge(dest, source, question string) (Challenge, ChallengeResult, bool) {
+	var found Challenge
+	var foundResult ChallengeResult
+	ok := false
+	for challenge, result := range room.Challenges {
+		if challenge.DestIP != dest || challenge.SourceIP != source || (challenge.Question != question && result.Ciphertext != question) {
+			continue
+		}
+
+		if !ok || (foundResult.Expired && !result.Expired) || (foundResult.Expired == result.Expired && challenge.ID > found.ID) {
+			found, foundResult, ok = challenge, result, true
+		}
+	}
+	return found, foundResult, ok
 }
 
 type Room struct {
@@ -56,6 +156,9 @@ type Room struct {
 
 	code string
 
+	// Authorizes the host's requests (see authorizeHost)
+	hostKey string
+
 	// --- Public room data --- //
 
 	// Metadata of the room (always available)
@@ -64,6 +167,9 @@ type Room struct {
 	// State is the current state of the room
 	State PublicState
 
+	// Options are the host-configurable rules of the room
+	Options RoomOptions `json:"options"`
+
 	// --- Private room data --- //
 
 	// Clients is a map from client session ID to client
@@ -74,6 +180,63 @@ type Room struct {
 
 	// Q/A Tables
 	QATables map[Name]QATable
+
+	// Every question and answer in the room (each player's table is a subset)
+	QABank QATable
+
+	// The instructor-authored question bank in use (nil for random symbols)
+	questionBank *QuestionBank
+
+	// The generator that created each generated question in the bank
+	generatedBy map[string]string
+
+	// The records each player resolved through the server's resolver, by hostname
+	Resolutions map[Name]map[string]Resolution
+
+	// Each player's secret key (only when questions are encrypted)
+	Keys map[Name]*CipherKey
+
+	// Each player's live responder tokens
+	Tokens map[Name][]string
+
+	// Players the host gave a special role (everyone else is honest)
+	Roles map[Name]Role
+
+	// The number of shields each player has used
+	ShieldsUsed map[Name]int
+
+	// Schedules power-ups and special events
+	rules RulesEngine
+
+	// The badges each player has earned
+	Badges map[Name][]Badge
+
+	// Every player's stats, cached by Stats (nil when they need recomputing)
+	stats   map[Name]PlayerStats
+	statsMu sync.Mutex
+
+	// Subnet requests waiting on the host's approval
+	Pending map[Name]SubnetRequest
+
+	// Addresses players were moved away from during the game. They aren't handed
+	// out again, so players keep the points they earned with them
+	Retired map[IP]Name
+
+	// Progress updates are throttled
+	progressPending bool
+	lastProgress    time.Time
+
+	// TCP connections between players (only in TCP mode)
+	Connections map[ConnectionKey]*TCPConnection
+
+	// Fragmented datagrams
+	Datagrams map[DatagramKey]*Datagram
+
+	// The last datagram ID handed out
+	nextDatagramID int
+
+	// The last challenge ID handed out
+	nextChallengeID int
 }
 
 func NewRoom(code string) *Room {
@@ -84,15 +247,34 @@ func NewRoom(code string) *Room {
 	}
 
 	return &Room{
-		code: code,
+		code:    code,
+		hostKey: newHostKey(),
 		Metadata: RoomMetadata{
 			NumSubnets:  4,
 			Subnets:     subnets,
 			IPAddresses: map[Name]IP{},
+			Teams:       map[int]string{},
+			Network: NetworkState{
+				Down:       []int{},
+				Partitions: [][2]int{},
+			},
 		},
-		Clients:    make(map[string]*Client),
-		Challenges: make(map[Challenge]ChallengeResult),
-		QATables:   make(map[Name]QATable),
+		Options:     DefaultRoomOptions(),
+		Clients:     make(map[string]*Client),
+		Challenges:  make(map[Challenge]ChallengeResult),
+		QATables:    make(map[Name]QATable),
+		QABank:      make(QATable),
+		generatedBy: make(map[string]string),
+		Resolutions: make(map[Name]map[string]Resolution),
+		Keys:        make(map[Name]*CipherKey),
+		Tokens:      make(map[Name][]string),
+		Roles:       make(map[Name]Role),
+		Retired:     make(map[IP]Name),
+		Pending:     make(map[Name]SubnetRequest),
+		Badges:      make(map[Name][]Badge),
+		ShieldsUsed: make(map[Name]int),
+		Connections: make(map[ConnectionKey]*TCPConnection),
+		Datagrams:   make(map[DatagramKey]*Datagram),
 	}
 }
 
@@ -102,7 +284,6 @@ const alphabet string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ012
 func (room *Room) NewClient() *Client {
 	// Lock the room
 	room.Lock()
-	defer room.Unlock()
 
 	// Generate a new session ID
 	sessionID := make([]byte, 32)
@@ -134,10 +315,26 @@ func (room *Room) NewClient() *Client {
 	room.Clients[id] = NewClient(id, name)
 
 	// Create the Q/A table
-	room.QATables[name] = NewQATable()
+	shared := room.AssignQATable(name)
+	room.AssignKey(name)
+	room.AssignTokens(name)
+
+	// Players who were given some of the new questions need to see them
+	var grown []*Client
+	for _, other := range room.Clients {
+		if containsName(shared, other.Name) {
+			grown = append(grown, other)
+		}
+	}
+	client := room.Clients[id]
+	room.Unlock()
+
+	for _, other := range grown {
+		room.SendUserdata(other)
+	}
 
 	// Return the session ID and name
-	return room.Clients[id]
+	return client
 }
 
 // AddConnection adds a websocket connection to the appropriate client
@@ -153,6 +350,20 @@ func (room *Room) AddConnection(sessionID string, conn *websocket.Conn) error {
 	return nil
 }
 
+// ClientByIP returns the client that has been assigned an IP address (or nil)
+func (room *Room) ClientByIP(ip IP) *Client {
+	name, ok := room.Metadata.Subnets[ip.Subnet][ip.Host]
+	if !ok {
+		return nil
+	}
+	for _, client := range room.Clients {
+		if client.Name == name {
+			return client
+		}
+	}
+	return nil
+}
+
 type RoomUserData struct {
 	// The user's name
 	Name Name `json:"name"`
@@ -160,9 +371,33 @@ type RoomUserData struct {
 	// The user's IP address
 	IP *IP `json:"ip,omitempty"`
 
+	// The user's hostname (only in DNS mode)
+	Hostname string `json:"hostname,omitempty"`
+
+	// Every DNS record (only sent to the student acting as the resolver)
+	Zone []DNSRecord `json:"zone,omitempty"`
+
+	// The user's secret key (only when questions are encrypted)
+	Key *CipherKey `json:"key,omitempty"`
+
+	// The user's live responder tokens. One of them is sent along with every reply, so the user gets credit
+	Tokens []string `json:"tokens,omitempty"`
+
+	// The user's secret role (empty for honest players)
+	Role Role `json:"role,omitempty"`
+
+	// The user's difficulty level
+	Level int `json:"level"`
+
 	// The user's score
 	Score int `json:"score"`
 
+	// The number of shields the user can use to refuse a challenge
+	Shields int `json:"shields,omitempty"`
+
+	// The badges the user has earned
+	Badges []Badge `json:"badges,omitempty"`
+
 	// The user's Q/A table
 	QATable QATable `json:"qa_table,omitempty"`
 }
@@ -180,12 +415,7 @@ func (room *Room) UserData(client *Client) RoomUserData {
 	}
 
 	// Get the user's score
-	score := 0
-	for challenge, result := range room.Challenges {
-		if challenge.SourceIP == result_ip.String() && result.Correct {
-			score++
-		}
-	}
+	score := room.Scores()[client.Name]
 
 	// Get the user's Q/A table
 	qaTable, ok := room.QATables[client.Name]
@@ -193,10 +423,29 @@ func (room *Room) UserData(client *Client) RoomUserData {
 		qaTable = nil
 	}
 
-	return RoomUserData{
+	userdata := RoomUserData{
 		Name:    client.Name,
 		IP:      result_ip,
 		Score:   score,
 		QATable: qaTable,
+		Key:     room.Keys[client.Name],
+		Tokens:  room.Tokens[client.Name],
+		Role:    room.Roles[client.Name],
+		Badges:  room.Badges[client.Name],
+		Shields: room.Shields(client.Name),
+	}
+
+	if ip, ok := room.Metadata.IPAddresses[client.Name]; ok {
+		userdata.Level = room.Level(ip)
 	}
+
+	// DNS mode
+	if room.Options.DNS {
+		userdata.Hostname = room.Hostname(client.Name)
+		if room.IsResolver(client) {
+			userdata.Zone = room.Zone()
+		}
+	}
+
+	return userdata
 }
@@ -52,6 +52,14 @@ type PublicState struct {
 	// Becomes available once the game starts
 	Scoreboard map[Name]int `json:"scoreboard,omitempty"`
 
+	// Badges are the badges every player has earned, shown on the scoreboard
+	Badges map[Name][]string `json:"badges,omitempty"`
+
+	// Teams is the scoreboard of every subnet (only in team mode)
+	//
+	// Becomes available once the game starts
+	Teams []TeamScore `json:"teams,omitempty"`
+
 	// Progress is the total number of messages sent/received by all players (optional)
 	//
 	// Becomes available once the game starts
@@ -60,6 +68,86 @@ type PublicState struct {
 	// Goal is the number of messages required to be sent/received (optional)
 	Goal int `json:"goal,omitempty"`
 
+	// StormUntil is the time the current broadcast storm ends (optional)
+	StormUntil time.Time `json:"stormUntil,omitempty"`
+
 	// EndTime is the time when the game will end (optional)
 	EndTime time.Time `json:"endTime,omitempty"`
+
+	// Events is every network event the host injected into the game, in order
+	Events []NetworkEvent `json:"events,omitempty"`
+
+	// Tournament is the progress of a multi-round tournament (optional)
+	//
+	// Becomes available once the first round starts
+	Tournament *Tournament `json:"tournament,omitempty"`
+}
+
+// How long players have to get ready after the host starts the game
+const countdown = 10 * time.Second
+
+// How long answers are still accepted after the game stops
+const gracePeriod = time.Minute
+
+// Start moves a waiting room to Starting, and to Running once the countdown ends
+//
+// The caller must hold the room's lock
+func (room *Room) Start() Message {
+	room.State.State = Starting
+	room.State.StartTime = time.Now().Add(countdown)
+	room.BeginRound()
+
+	time.AfterFunc(countdown, func() {
+		room.Lock()
+		if room.State.State == Starting {
+			room.State.State = Running
+		}
+		room.Unlock()
+		room.BroadcastGameState()
+	})
+
+	return Message{
+		Type: Start,
+		Payload: StartMessage{
+			StartTime: room.State.StartTime,
+		},
+	}
+}
+
+// Stop moves a running room to Stopping. Answers are accepted for the grace
+// period, after which the room is Stopped.
+//
+// The caller must hold the room's lock
+func (room *Room) Stop() Message {
+	room.State.State = Stopping
+	room.State.EndTime = time.Now().Add(gracePeriod)
+
+	time.AfterFunc(gracePeriod, func() {
+		room.Lock()
+		var summary *Message
+		var awards map[*Client][]Badge
+		if room.State.State == Stopping {
+			room.State.State = Stopped
+			awards = room.AwardRecords()
+			room.UpdateScoreboard()
+			summary = room.EndRound()
+		}
+		room.Unlock()
+		room.BroadcastGameState()
+
+		notifyBadges(awards)
+		for client := range awards {
+			room.SendUserdata(client)
+		}
+		if summary != nil {
+			room.Broadcast(*summary)
+		}
+	})
+
+	return Message{
+		Type: Stop,
+		Payload: StopMessage{
+			StopTime: room.State.EndTime,
+		},
+	}
 }
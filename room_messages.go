@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"time"
 )
 
@@ -40,6 +39,76 @@ func (room *Room) HandleClientMessages(client *Client) {
 			room.Answer(client, msg)
 		case RequestMetadata:
 			room.SendMetadata(client)
+		case Resolve:
+			msg, ok := msg.Payload.(ResolveMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected ResolveMessage"))
+				continue
+			}
+			room.Resolve(client, msg)
+		case Syn, SynAck, Ack, Data, Fin:
+			payload, ok := msg.Payload.(SegmentMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected SegmentMessage"))
+				continue
+			}
+			room.Segment(client, msg.Type, payload)
+		case SendFragment:
+			msg, ok := msg.Payload.(FragmentMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected FragmentMessage"))
+				continue
+			}
+			room.SendFragment(client, msg)
+		case Reassemble:
+			msg, ok := msg.Payload.(ReassembleMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected ReassembleMessage"))
+				continue
+			}
+			room.Reassemble(client, msg)
+		case Relay:
+			msg, ok := msg.Payload.(RelayMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected RelayMessage"))
+				continue
+			}
+			room.Relay(client, msg)
+		case Spoof:
+			msg, ok := msg.Payload.(SpoofMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected SpoofMessage"))
+				continue
+			}
+			room.Spoof(client, msg)
+		case Verify:
+			msg, ok := msg.Payload.(VerifyMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected VerifyMessage"))
+				continue
+			}
+			room.Verify(client, msg)
+		case Sniff:
+			msg, ok := msg.Payload.(SniffMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected SniffMessage"))
+				continue
+			}
+			room.SubmitSniff(client, msg)
+		case Shield:
+			msg, ok := msg.Payload.(ShieldMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected ShieldMessage"))
+				continue
+			}
+			room.UseShield(client, msg)
+		case Reply:
+			msg, ok := msg.Payload.(ReplyMessage)
+			if !ok {
+				_ = client.Send(NewError("INVALID_PAYLOAD: Expected ReplyMessage"))
+				continue
+			}
+			room.Reply(client, msg)
 		}
 	}
 
@@ -62,8 +131,44 @@ func (room *Room) Broadcast(msg Message) {
 }
 
 // BroadcastMetadata sends the room Metadata to all clients in the room
+//
+// Addresses may have changed, so a student running the DNS resolver is also sent their new zone
 func (room *Room) BroadcastMetadata() {
 	room.Broadcast(NewMetadataMessage(room.Metadata))
+
+	room.RLock()
+	var resolver *Client
+	if room.Options.DNS {
+		resolver = room.Resolver()
+	}
+	room.RUnlock()
+
+	if resolver != nil {
+		room.SendUserdata(resolver)
+	}
+}
+
+// BroadcastGameState sends the room's public state to all clients in the room
+func (room *Room) BroadcastGameState() {
+	room.RLock()
+	state := room.State
+	room.RUnlock()
+
+	room.Broadcast(NewGameStateMessage(state))
+}
+
+// BroadcastUserdata sends every client in the room their own user data
+func (room *Room) BroadcastUserdata() {
+	room.RLock()
+	clients := make([]*Client, 0, len(room.Clients))
+	for _, client := range room.Clients {
+		clients = append(clients, client)
+	}
+	room.RUnlock()
+
+	for _, client := range clients {
+		room.SendUserdata(client)
+	}
 }
 
 // JoinSubnet is called to handle a JoinSubnet message
@@ -71,8 +176,9 @@ func (room *Room) JoinSubnet(client *Client, msg JoinSubnetMessage) {
 	// Subnet joins are only allowed while the room is in "Waiting" state
 	room.Lock()
 	if room.State.State != Waiting {
-		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Attempted to join subnet while game is not waiting (state: %d)", room.State.State)))
+		// Late joiners and subnet changes follow the room's policies
 		room.Unlock()
+		room.JoinLate(client, msg)
 		return
 	}
 
@@ -84,20 +190,10 @@ func (room *Room) JoinSubnet(client *Client, msg JoinSubnetMessage) {
 	}
 
 	// Remove the client from its existing subnet
-	ip, ok := room.Metadata.IPAddresses[client.Name]
-	if ok {
-		delete(room.Metadata.Subnets[ip.Subnet], ip.Host)
-	}
+	room.leaveSubnet(client.Name)
 
-	// Choose the smallest host number that is not taken
-	for host := 1; host <= 255; host++ {
-		if _, ok := room.Metadata.Subnets[msg.Subnet][host]; !ok {
-			// Found a free host number
-			room.Metadata.Subnets[msg.Subnet][host] = client.Name
-			room.Metadata.IPAddresses[client.Name] = IP{msg.Subnet, host}
-			_ = client.Send(NewAssignedIPMessage(IP{msg.Subnet, host}))
-			break
-		}
+	if ip, ok := room.assignAddress(client.Name, msg.Subnet); ok {
+		_ = client.Send(NewAssignedIPMessage(ip))
 	}
 	room.Unlock()
 
@@ -106,8 +202,58 @@ func (room *Room) JoinSubnet(client *Client, msg JoinSubnetMessage) {
 	room.BroadcastMetadata()
 }
 
+// leaveSubnet removes a player from the subnet they are in (if any)
+//
+// The caller must hold the room's lock
+func (room *Room) leaveSubnet(name Name) {
+	if ip, ok := room.Metadata.IPAddresses[name]; ok {
+		delete(room.Metadata.Subnets[ip.Subnet], ip.Host)
+		delete(room.Metadata.IPAddresses, name)
+		room.forgetStats()
+	}
+}
+
+// assignAddress gives a player the smallest free host number in the subnet
+//
+// The caller must hold the room's lock
+func (room *Room) assignAddress(name Name, subnet int) (IP, bool) {
+	for host := 1; host < BroadcastHost; host++ {
+		// The NAT gateway's address can't be handed out
+		if room.Metadata.NAT != nil && room.Metadata.NAT.IsReserved(IP{subnet, host}) {
+			continue
+		}
+
+		// Neither can the addresses players were moved away from
+		if _, ok := room.Retired[IP{subnet, host}]; ok {
+			continue
+		}
+
+		if _, ok := room.Metadata.Subnets[subnet][host]; !ok {
+			// Found a free host number
+			room.Metadata.Subnets[subnet][host] = name
+			room.Metadata.IPAddresses[name] = IP{subnet, host}
+			room.forgetStats()
+			return IP{subnet, host}, true
+		}
+	}
+	return IP{}, false
+}
+
 // RequestChallenge is called to handle a RequestChallenge message
+//
+// During a broadcast storm every request creates extra challenges
 func (room *Room) RequestChallenge(client *Client, msg RequestChallengeMessage) {
+	room.RLock()
+	copies := room.Copies()
+	room.RUnlock()
+
+	for i := 0; i < copies; i++ {
+		room.requestChallenge(client, msg)
+	}
+}
+
+// requestChallenge creates a single challenge for the client
+func (room *Room) requestChallenge(client *Client, msg RequestChallengeMessage) {
 	room.Lock()
 	// Challenges can only be requested while the room is in "Running" state
 	if room.State.State != Running {
@@ -116,50 +262,189 @@ func (room *Room) RequestChallenge(client *Client, msg RequestChallengeMessage)
 		return
 	}
 
-	// Get a random host that isn't the client
-	sourceIP := room.Metadata.IPAddresses[client.Name]
-	var destIP IP
-	for {
-		// Choose a random subnet
-		destSubnet := rand.Intn(room.Metadata.NumSubnets) + 1
-		subnet := room.Metadata.Subnets[destSubnet]
+	sourceIP, ok := room.address(client)
+	if !ok {
+		room.Unlock()
+		return
+	}
+	nat := room.Metadata.NAT
+
+	// Limit how many challenges a client can have in flight at once
+	if limit := room.Options.MaxOutstanding * room.Copies(); limit > 0 && room.Outstanding(sourceIP) >= limit {
+		_ = client.Send(NewError(fmt.Sprintf("TOO_MANY_CHALLENGES: You already have %d outstanding challenges", limit)))
+		room.Unlock()
+		return
+	}
+
+	// Some challenges are addressed to a group of hosts instead of a single host
+	groupAddr, recipients := room.ChooseGroup(sourceIP)
 
-		// Choose a random key from the subnet
-		host, n := RandomEntry(subnet)
+	// Only the members that can be reached receive it
+	var reachable []IP
+	for _, ip := range recipients {
+		if room.Metadata.Network.Reachable(sourceIP, ip) {
+			reachable = append(reachable, ip)
+		}
+	}
+	if recipients = reachable; len(recipients) == 0 {
+		groupAddr = ""
+	}
 
-		// Verify that this destination is not the client
-		if n != client.Name {
-			destIP = IP{destSubnet, host}
-			break
+	// Otherwise choose a host that isn't the client
+	var destIP IP
+	if groupAddr == "" {
+		var ok bool
+		destIP, ok = room.ChooseDestination(sourceIP)
+		if !ok {
+			_ = client.Send(NewError("NO_DESTINATIONS: There is nobody you can send a challenge to"))
+			room.Unlock()
+			return
 		}
 	}
 
-	// Generate a new challenge
+	destination := destIP.String()
+	targets := []IP{destIP}
+	if groupAddr != "" {
+		destination = groupAddr
+		targets = recipients
+	}
+
+	// Some challenges are relayed through a chain of hosts
+	var hops []IP
+	var hopQuestions []string
+	if groupAddr == "" {
+		hops = room.ChooseHops(sourceIP, destIP)
+	}
+
+	// Fast players are sent longer questions, which the destination has to be given
+	var lengthened *Client
+	if hops == nil && groupAddr == "" && room.Harder(sourceIP, LongQuestionLevel) && room.addLongQuestion(sourceIP, destIP) {
+		lengthened = room.ClientByIP(destIP)
+	}
+
+	// Generate a new challenge the destination can answer
 	var challenge Challenge
-	for {
-		question, answer := RandomEntry(room.QATables[client.Name])
-		challenge = Challenge{
-			DestIP:   destIP.String(),
-			SourceIP: sourceIP.String(),
-			Question: question,
-			Answer:   answer,
+	var holders []IP
+	if hops != nil {
+		challenge, hopQuestions, ok = room.NewChain(client.Name, sourceIP, hops)
+	} else {
+		challenge, holders, ok = room.ChooseQuestion(client.Name, sourceIP, destination, targets)
+	}
+	if !ok {
+		_ = client.Send(NewError(fmt.Sprintf("NO_QUESTIONS: %s has no questions left for you", destination)))
+		room.Unlock()
+		return
+	}
+
+	// Only the group members holding the question can reply
+	if groupAddr != "" {
+		recipients = holders
+	}
+
+	// Questions larger than the path MTU have to be fragmented
+	var header *DatagramHeader
+	result := ChallengeResult{
+		Correct:      false,
+		Created:      time.Now(),
+		Recipients:   recipients,
+		Hops:         hops,
+		HopQuestions: hopQuestions,
+		Level:        room.Level(sourceIP),
+	}
+	room.ApplyPowerUps(&result)
+
+	// Generated hex and binary answers may be written with their radix prefix
+	answered := challenge.Question
+	if len(hopQuestions) > 0 {
+		answered = hopQuestions[len(hopQuestions)-1]
+	}
+	result.Radix = RadixPrefixes[room.generatedBy[answered]]
+	var rekeyed *Client
+	if groupAddr == "" {
+		destName := room.Metadata.Subnets[destIP.Subnet][destIP.Host]
+
+		// Fast players have their questions encrypted, even if the room doesn't use a cipher
+		encrypt := room.Options.Cipher != NoCipher || room.Harder(sourceIP, EncryptedLevel)
+		if _, ok := room.Keys[destName]; !ok && encrypt {
+			room.Keys[destName] = NewCipherKey(RSACipher)
+			rekeyed = room.ClientByIP(destIP)
+		}
+
+		// Encrypt the question with the destination's key
+		if key, ok := room.Keys[destName]; ok && encrypt {
+			result.Ciphertext = key.Encrypt(challenge.Question)
 		}
 
-		// Verify that this challenge doesn't already exist
-		if _, ok := room.Challenges[challenge]; !ok {
-			break
+		if datagram := room.NewDatagram(sourceIP, destIP, result.Transmitted(challenge)); datagram != nil {
+			header = &DatagramHeader{ID: datagram.ID, MTU: datagram.MTU}
+			result.DatagramID = datagram.ID
 		}
 	}
 
+	// Packets leaving a private subnet for the public subnet are source-NATed.
+	// The destination only ever sees (and replies to) the translated address
+	var source string
+	var receiver *Client
+	translated := groupAddr == "" && nat != nil && nat.IsPrivate(sourceIP) && !nat.IsPrivate(destIP)
+	if translated {
+		mapping := nat.Translate(sourceIP)
+		result.Translation = &mapping
+		source = mapping.PublicAddress()
+		receiver = room.ClientByIP(destIP)
+	}
+
 	// Add the challenge to the room
-	room.Challenges[challenge] = ChallengeResult{
-		Correct: false,
-		Created: time.Now(),
+	room.nextChallengeID++
+	challenge.ID = room.nextChallengeID
+	room.record(challenge, result)
+
+	// In DNS mode the client is only told the destination's hostname
+	if groupAddr == "" && room.Options.DNS {
+		destination = room.Hostname(room.Metadata.Subnets[destIP.Subnet][destIP.Host])
 	}
 
 	// Send the challenge to the client
-	_ = client.Send(NewCreateChallengeMessage(challenge.DestIP, challenge.Question))
+	_ = client.Send(NewCreateChallengeMessage(destination, source, result.Transmitted(challenge), header, result.Priority))
+
+	// Spoofers see every unicast challenge, so they can forge a reply,
+	// and sniffers see every unicast challenge crossing their subnet
+	var eavesdroppers []*Client
+	if groupAddr == "" {
+		eavesdroppers = append(room.WithRole(SpooferRole, sourceIP, destIP), room.Sniffers(sourceIP, destIP)...)
+	}
 	room.Unlock()
+
+	intercepted := NewInterceptedMessage(challenge.SourceIP, challenge.DestIP, result.Transmitted(challenge))
+	for _, eavesdropper := range eavesdroppers {
+		_ = eavesdropper.Send(intercepted)
+	}
+
+	// The translation table is part of the room's Metadata, and the destination
+	// is told the translated address it has to reply to
+	if translated {
+		if receiver != nil {
+			_ = receiver.Send(NewTranslatedMessage(source))
+		}
+		room.BroadcastMetadata()
+	}
+
+	// The destination needs its new key to decrypt the question, and its new entry to answer it
+	if rekeyed != nil {
+		room.SendUserdata(rekeyed)
+	}
+	if lengthened != nil && lengthened != rekeyed {
+		room.SendUserdata(lengthened)
+	}
+
+	// Every hop but the last was given a new table entry
+	for _, hop := range hops[:max(len(hops)-1, 0)] {
+		room.RLock()
+		relay := room.ClientByIP(hop)
+		room.RUnlock()
+		if relay != nil {
+			room.SendUserdata(relay)
+		}
+	}
 }
 
 // Answer is called to handle an Answer message
@@ -175,18 +460,21 @@ func (room *Room) Answer(client *Client, msg AnswerMessage) {
 	}
 
 	// Get the user's IP address
-	ip := room.Metadata.IPAddresses[client.Name].String()
+	address, ok := room.address(client)
+	if !ok {
+		room.Unlock()
+		return
+	}
+	ip := address.String()
 
-	// Check if the challenge exists
-	challenge := Challenge{
-		DestIP:   msg.Destination,
-		SourceIP: ip,
-		Question: msg.Question,
-		Answer:   msg.Answer,
+	// The destination may have been given as a hostname
+	destination := msg.Destination
+	if record, ok := room.LookupHostname(destination); ok {
+		destination = record.IP.String()
 	}
 
 	// Check if the challenge exists
-	result, ok := room.Challenges[challenge]
+	challenge, result, ok := room.FindChallenge(destination, ip, msg.Question)
 	if !ok {
 		// Challenge doesn't exist
 		_ = client.Send(NewError("Challenge doesn't exist"))
@@ -194,17 +482,179 @@ func (room *Room) Answer(client *Client, msg AnswerMessage) {
 		return
 	}
 
-	// If the user guessed the right answer then we mark the challenge as solved
-	correct := msg.Answer == challenge.Answer
-	if correct {
-		room.Challenges[challenge] = ChallengeResult{
-			Correct: true,
-			Created: result.Created,
+	// Expired challenges can no longer be answered
+	if result.Expired {
+		_ = client.Send(NewError(fmt.Sprintf("EXPIRED: The challenge to %s timed out", challenge.DestIP)))
+		room.Unlock()
+		return
+	}
+
+	// Once answered correctly, a unicast challenge is done
+	if result.Correct && len(result.Recipients) == 0 {
+		_ = client.Send(NewError(fmt.Sprintf("ALREADY_ANSWERED: The challenge to %s has already been answered", challenge.DestIP)))
+		room.Unlock()
+		return
+	}
+
+	// Each challenge only allows a limited number of wrong answers (a group's correct
+	// replies are not mistakes, so they don't use any up)
+	if limit := room.Options.Scoring.MaxAttempts; limit > 0 && result.Wrong >= limit {
+		_ = client.Send(NewError(fmt.Sprintf("NO_ATTEMPTS_LEFT: The challenge to %s has used all %d wrong attempts", challenge.DestIP, limit)))
+		room.Unlock()
+		return
+	}
+
+	// Equivalent answers ("b3", "0xB3" for a generated hex answer) are accepted
+	correct := result.Matches(challenge, msg.Answer)
+
+	// The answer only counts once it actually made it back to the sender. Undelivered
+	// answers still use up an attempt and wrong ones are still penalized, so skipping
+	// the protocol doesn't make guessing free, but correct ones earn nothing
+	if err := room.checkDelivery(client, challenge, result, msg); err != nil {
+		result.Attempts++
+		if !correct {
+			result.Wrong++
+		}
+		room.record(challenge, result)
+		room.UpdateScoreboard()
+		_ = client.Send(NewError(err.Error()))
+		room.Unlock()
+
+		room.SendUserdata(client)
+		room.BroadcastGameState()
+		return
+	}
+
+	// The host that looked up the answer
+	responder, _ := ParseIP(challenge.DestIP)
+
+	// Group challenges are answered once for every recipient that replied. Only the
+	// recipient's responder token proves who replied, so the sender can't pick
+	if len(result.Recipients) > 0 {
+		var ok bool
+		responder, ok = room.TokenOwner(msg.ResponderToken)
+		if !ok || !containsIP(result.Recipients, responder) {
+			_ = client.Send(NewError(fmt.Sprintf("NOT_A_RECIPIENT: The reply's responder token %q doesn't belong to a recipient of this challenge", msg.ResponderToken)))
+			room.Unlock()
+			return
+		}
+		if containsIP(result.Responders, responder) {
+			_ = client.Send(NewError(fmt.Sprintf("DUPLICATE_REPLY: %s has already replied to this challenge", responder)))
+			room.Unlock()
+			return
 		}
+
+		if correct {
+			result.Responders = append(result.Responders, responder)
+			result.Correct = result.Delivered(room.Options.GroupDelivery)
+		}
+	} else if correct {
+		// If the user guessed the right answer then we mark the challenge as solved
+		result.Correct = true
+	}
+
+	// The responder is credited if their token was relayed back with the answer
+	var credited *Client
+	if correct {
+		credited = room.RedeemToken(&result, responder, msg.ResponderToken)
+	}
+
+	// The client fell for a forged reply
+	var spoofer *Client
+	if i := result.Forged(msg.Answer); !correct && i >= 0 {
+		result.Forgeries[i].Accepted = true
+		spoofer = room.ClientByIP(result.Forgeries[i].Spoofer)
+	}
+
+	// Keep track of attempts, wrong answers cost points
+	result.Attempts++
+	if !correct {
+		result.Wrong++
+	}
+	if result.Correct && result.Answered.IsZero() {
+		result.Answered = time.Now()
+	}
+	room.record(challenge, result)
+
+	// The challenge is finished, so the gateway forgets its translation
+	released := result.Correct && room.releaseMapping(result)
+
+	// Both the sender and the responder may have earned something
+	earners := []Name{client.Name}
+	if credited != nil {
+		earners = append(earners, credited.Name)
+	}
+	awards := room.CheckAchievements(earners...)
+	room.UpdateScoreboard()
+
+	// Every correct answer brings the class closer to its goal
+	var stop *Message
+	if correct {
+		stop = room.AddProgress()
 	}
 
 	// Send the user a response, communicating if they got the answer right
-	_ = client.Send(NewGradeMessage(msg.Destination, msg.Question, correct))
+	_ = client.Send(NewGradeMessage(msg.Destination, msg.Question, correct, len(result.Responders), credited != nil))
+	room.Unlock()
+
+	// Scores have changed, and the responder needs their new token
+	notifyBadges(awards)
+	room.SendUserdata(client)
+	if credited != nil {
+		room.SendUserdata(credited)
+	}
+	if spoofer != nil {
+		room.SendUserdata(spoofer)
+	}
+	room.BroadcastGameState()
+	if released {
+		room.BroadcastMetadata()
+	}
+
+	// The class reached its goal
+	if stop != nil {
+		room.Broadcast(*stop)
+	}
+}
+
+// checkDelivery returns an error unless the challenge's question and answer went through
+// every step the room's network requires
+//
+// The caller must hold the room's lock
+func (room *Room) checkDelivery(client *Client, challenge Challenge, result ChallengeResult, msg AnswerMessage) error {
+	sender := room.Metadata.IPAddresses[client.Name]
+
+	// In TCP mode the question and answer must have travelled over a connection
+	if room.Options.TCP && len(result.Recipients) == 0 && !room.DeliveredOverTCP(challenge, result) {
+		return fmt.Errorf("NOT_DELIVERED: The question and answer were never exchanged over a connection with %s", challenge.DestIP)
+	}
+
+	// In DNS mode the destination's address must have come from a fresh record
+	if dest, err := ParseIP(challenge.DestIP); err == nil && len(result.Recipients) == 0 {
+		if err := room.CheckResolved(client.Name, dest, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	// Replies to a source-NATed challenge are forwarded by the gateway
+	if err := room.Forwarded(result, sender, msg.ReplyTo); err != nil {
+		return err
+	}
+
+	// Fragmented questions must have been reassembled by the destination
+	if result.DatagramID != 0 {
+		datagram := room.Datagrams[DatagramKey{sender, result.DatagramID}]
+		if datagram == nil || !datagram.Reassembled {
+			return fmt.Errorf("NOT_REASSEMBLED: Datagram %d has not been reassembled by %s", result.DatagramID, challenge.DestIP)
+		}
+	}
+
+	// Every intermediate hop of a chain must have relayed its token
+	if len(result.Hops) > 0 && result.Progress < len(result.Hops)-1 {
+		return fmt.Errorf("CHAIN_INCOMPLETE: Only %d of %d hops have relayed this challenge", result.Progress, len(result.Hops)-1)
+	}
+
+	return nil
 }
 
 // SendMetadata sends the room Metadata to the client
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testRoom is a running room whose players' messages are kept instead of sent over a websocket
+type testRoom struct {
+	*Room
+
+	// The players, in the order they joined. Player i is in subnet i%NumSubnets+1
+	players []*Client
+}
+
+// newTestRoom creates a running room with the given number of players spread over its subnets
+func newTestRoom(t *testing.T, players int, configure func(*RoomOptions)) *testRoom {
+	t.Helper()
+
+	room := &testRoom{Room: NewRoom("TEST")}
+	for i := 0; i < players; i++ {
+		client := room.NewClient()
+
+		// Nothing reads the messages, so they are buffered until the test looks at them
+		client.send = make(chan []byte, 1024)
+		room.players = append(room.players, client)
+	}
+
+	opts := room.Options
+	if configure != nil {
+		configure(&opts)
+	}
+	if err := room.SetOptions(opts); err != nil {
+		t.Fatalf("SetOptions: %v", err)
+	}
+
+	for i, client := range room.players {
+		room.JoinSubnet(client, JoinSubnetMessage{Subnet: i%room.Metadata.NumSubnets + 1})
+	}
+	room.State.State = Running
+	room.discard()
+	return room
+}
+
+// ip returns a player's address
+func (room *testRoom) ip(client *Client) IP {
+	room.RLock()
+	defer room.RUnlock()
+	return room.Metadata.IPAddresses[client.Name]
+}
+
+// received returns the payloads of every message of the given type the client was sent
+// since the last call, throwing away every other message
+func (room *testRoom) received(client *Client, kind MessageType) []json.RawMessage {
+	var payloads []json.RawMessage
+	for len(client.send) > 0 {
+		var msg struct {
+			Type    MessageType     `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(<-client.send, &msg); err == nil && msg.Type == kind {
+			payloads = append(payloads, msg.Payload)
+		}
+	}
+	return payloads
+}
+
+// failures returns the error messages the client was sent since the last call
+func (room *testRoom) failures(client *Client) []string {
+	var errs []string
+	for _, payload := range room.received(client, Error) {
+		var msg ErrorMessage
+		_ = json.Unmarshal(payload, &msg)
+		errs = append(errs, msg.Message)
+	}
+	return errs
+}
+
+// discard throws away every message sent so far
+func (room *testRoom) discard() {
+	for _, client := range room.players {
+		room.received(client, Error)
+	}
+}
+
+// request has the client request a challenge, returning the challenge that was created
+func (room *testRoom) request(t *testing.T, client *Client) (Challenge, ChallengeResult) {
+	t.Helper()
+
+	room.RLock()
+	before := make(map[Challenge]bool, len(room.Challenges))
+	for challenge := range room.Challenges {
+		before[challenge] = true
+	}
+	room.RUnlock()
+
+	room.RequestChallenge(client, RequestChallengeMessage{})
+
+	room.RLock()
+	defer room.RUnlock()
+	for challenge, result := range room.Challenges {
+		if !before[challenge] {
+			return challenge, result
+		}
+	}
+	t.Fatalf("%s was not given a challenge", client.Name)
+	return Challenge{}, ChallengeResult{}
+}
+
+// result returns the challenge's current result
+func (room *testRoom) result(challenge Challenge) ChallengeResult {
+	room.RLock()
+	defer room.RUnlock()
+	return room.Challenges[challenge]
+}
+
+// serve registers the room and sends it an HTTP request, signed with the host key if
+// host is true. vars are the route's variables besides the room code
+func (room *testRoom) serve(t *testing.T, handler http.HandlerFunc, method string, host bool, query string, body string, vars map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rooms.Lock()
+	rooms.Rooms[room.code] = room.Room
+	rooms.Unlock()
+	t.Cleanup(func() {
+		rooms.Lock()
+		delete(rooms.Rooms, room.code)
+		rooms.Unlock()
+	})
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("bad query %q: %v", query, err)
+	}
+	if host {
+		values.Set("key", room.hostKey)
+	}
+
+	r := httptest.NewRequest(method, "/room/"+room.code+"?"+values.Encode(), strings.NewReader(body))
+	routeVars := map[string]string{"code": room.code}
+	for k, v := range vars {
+		routeVars[k] = v
+	}
+	r = mux.SetURLVars(r, routeVars)
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
@@ -27,6 +31,8 @@ func (r *Rooms) NewRoom(code string) *Room {
 		code = randomSymbol()
 	}
 	room := NewRoom(code)
+	go room.ExpireChallenges()
+	go room.RunRules()
 
 	r.Lock()
 	r.Rooms[code] = room
@@ -35,6 +41,29 @@ func (r *Rooms) NewRoom(code string) *Room {
 	return room
 }
 
+// newHostKey returns a random key for the host to authorize their requests with
+func newHostKey() string {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(key)
+}
+
+// authorizeHost checks that a request comes from the host: host actions are POSTs
+// carrying the room's host key (?key=). Otherwise it responds with an error and returns false
+func authorizeHost(w http.ResponseWriter, r *http.Request, room *Room) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(room.hostKey)) != 1 {
+		http.Error(w, "Invalid host key", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // WebsocketHandler handles incoming websocket connections
 // /room/{code}/ws
 func WebsocketHandler(w http.ResponseWriter, r *http.Request) {
@@ -124,3 +153,117 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, cookie)
 	w.WriteHeader(http.StatusOK)
 }
+
+// OptionsHandler lets anyone read (GET), and the host replace (POST), the room's options
+// /room/{code}/options?key=<key>
+func OptionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := vars["code"]
+	if code == "" {
+		http.Error(w, "Missing room code", http.StatusBadRequest)
+		return
+	}
+
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !authorizeHost(w, r, room) {
+			return
+		}
+
+		// Start from a copy of the current options so partial updates are possible,
+		// and a rejected update leaves the room untouched
+		room.RLock()
+		opts, err := room.Options.clone()
+		room.RUnlock()
+		if err != nil {
+			http.Error(w, "Failed to copy options", http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, "Failed to decode options", http.StatusBadRequest)
+			return
+		}
+
+		if err := room.SetOptions(opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	room.RLock()
+	defer room.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(room.Options)
+}
+
+// StartHandler lets the host start the game (after a short countdown)
+// /room/{code}/start?key=<key>
+func StartHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeHost(w, r, room) {
+		return
+	}
+
+	room.Lock()
+	if room.State.State != Waiting {
+		room.Unlock()
+		http.Error(w, "The game can only be started while the room is waiting", http.StatusBadRequest)
+		return
+	}
+	msg := room.Start()
+	room.Unlock()
+
+	room.Broadcast(msg)
+	room.BroadcastGameState()
+	w.WriteHeader(http.StatusOK)
+}
+
+// StopHandler lets the host stop the game (answers are accepted for a grace period)
+// /room/{code}/stop?key=<key>
+func StopHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeHost(w, r, room) {
+		return
+	}
+
+	room.Lock()
+	if room.State.State != Running {
+		room.Unlock()
+		http.Error(w, "The game can only be stopped while it is running", http.StatusBadRequest)
+		return
+	}
+	msg := room.Stop()
+	room.Unlock()
+
+	room.Broadcast(msg)
+	room.BroadcastGameState()
+	w.WriteHeader(http.StatusOK)
+}
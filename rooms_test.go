@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOptionsNeedTheHostKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		host   bool
+		query  string
+		status int
+	}{
+		{"anyone can read the options", http.MethodGet, false, "", http.StatusOK},
+		{"host changes the options", http.MethodPost, true, "", http.StatusOK},
+		{"without a key", http.MethodPost, false, "", http.StatusForbidden},
+		{"with the wrong key", http.MethodPost, false, "key=guess", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 0, nil)
+			room.State.State = Waiting
+
+			w := room.serve(t, OptionsHandler, tt.method, tt.host, tt.query, `{"coop_goal":7}`, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if changed := room.Options.CoopGoal == 7; changed != (tt.method == http.MethodPost && tt.status == http.StatusOK) {
+				t.Errorf("options changed: %v", changed)
+			}
+		})
+	}
+}
+
+func TestHostKeysAreUnique(t *testing.T) {
+	a, b := NewRoom("A"), NewRoom("B")
+	if a.hostKey == "" || a.hostKey == b.hostKey {
+		t.Errorf("host keys %q and %q, want two different keys", a.hostKey, b.hostKey)
+	}
+}
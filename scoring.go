@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ScoringRules decide how many points challenges are worth
+type ScoringRules struct {
+	// Points the sender earns for a correct answer (per replying host for group challenges)
+	CorrectPoints int `json:"correct_points"`
+
+	// Points the sender loses for every wrong answer
+	WrongPenalty int `json:"wrong_penalty"`
+
+	// The most wrong answers that can be submitted for a single challenge (0 is unlimited)
+	MaxAttempts int `json:"max_attempts"`
+
+	// Extra points for an instant answer, decaying linearly to nothing over TimeBonusWindow
+	TimeBonus int `json:"time_bonus"`
+
+	// How long (in seconds) the time bonus lasts
+	TimeBonusWindow int `json:"time_bonus_window"`
+
+	// Points earned by every host that looked up (or relayed) the answer
+	ResponderPoints int `json:"responder_points"`
+
+	// Points a spoofer earns when their forged reply is submitted
+	SpoofPoints int `json:"spoof_points"`
+
+	// Points the sender earns for catching a forged reply
+	CatchPoints int `json:"catch_points"`
+
+	// Points the sender spends every time they verify a reply
+	VerifyCost int `json:"verify_cost"`
+
+	// Points a sniffer earns for answering an intercepted challenge before its sender
+	SniffPoints int `json:"sniff_points"`
+}
+
+// DefaultScoringRules returns the scoring rules used by newly created rooms
+func DefaultScoringRules() ScoringRules {
+	return ScoringRules{
+		CorrectPoints:   1,
+		ResponderPoints: 1,
+		SpoofPoints:     2,
+		CatchPoints:     1,
+		SniffPoints:     2,
+	}
+}
+
+// Validate checks that the rules make sense
+func (rules ScoringRules) Validate() error {
+	if rules.CorrectPoints < 0 || rules.WrongPenalty < 0 || rules.TimeBonus < 0 || rules.ResponderPoints < 0 ||
+		rules.SpoofPoints < 0 || rules.CatchPoints < 0 || rules.VerifyCost < 0 || rules.SniffPoints < 0 {
+		return fmt.Errorf("points and penalties must not be negative")
+	}
+	if rules.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative (got %d)", rules.MaxAttempts)
+	}
+	if rules.TimeBonus > 0 && rules.TimeBonusWindow <= 0 {
+		return fmt.Errorf("a time bonus needs a positive time bonus window")
+	}
+	return nil
+}
+
+// SenderPoints returns how much a challenge contributes to its sender's score
+func (rules ScoringRules) SenderPoints(result ChallengeResult) int {
+	points := -rules.WrongPenalty*result.Wrong - rules.VerifyCost*result.Verifications
+	for _, forgery := range result.Forgeries {
+		if forgery.Caught {
+			points += rules.CatchPoints
+		}
+	}
+	if !result.Correct {
+		return points
+	}
+
+	// Group challenges are worth points for every host that replied
+	if len(result.Recipients) > 0 {
+		points += rules.CorrectPoints * len(result.Responders)
+	} else {
+		points += rules.CorrectPoints
+	}
+
+	// Faster answers earn a bonus
+	if rules.TimeBonus > 0 {
+		window := time.Duration(rules.TimeBonusWindow) * time.Second
+		if elapsed := result.Answered.Sub(result.Created); elapsed < window {
+			points += int(float64(rules.TimeBonus) * float64(window-elapsed) / float64(window))
+		}
+	}
+
+	return points
+}
+
+// Credited returns every host that gets credit for a correctly answered challenge
+//
+// Hops of a chain prove themselves by relaying, everyone else needs their
+// responder token relayed back by the sender
+func (result ChallengeResult) Credited() []IP {
+	if !result.Correct {
+		return nil
+	}
+	if len(result.Hops) > 0 {
+		return result.Hops
+	}
+	return result.Confirmed
+}
+
+// nameOf returns the player an address belongs to, or belonged to before they were moved
+func (room *Room) nameOf(ip IP) (Name, bool) {
+	if name, ok := room.Metadata.Subnets[ip.Subnet][ip.Host]; ok {
+		return name, true
+	}
+	name, ok := room.Retired[ip]
+	return name, ok
+}
+
+// Scores computes every player's score from the room's challenges
+func (room *Room) Scores() map[Name]int {
+	rules := room.Options.Scoring
+	scores := make(map[Name]int)
+	for _, client := range room.Clients {
+		scores[client.Name] = 0
+	}
+
+	for challenge, result := range room.Challenges {
+		if source, err := ParseIP(challenge.SourceIP); err == nil {
+			if name, ok := room.nameOf(source); ok {
+				points := rules.SenderPoints(result)
+
+				// Priority packets multiply what they earn (but not what they cost)
+				if result.Priority && points > 0 {
+					points *= room.Options.PowerUps.PriorityMultiplier
+				}
+				scores[name] += room.Options.Difficulty.handicapped(points, result)
+			}
+		}
+
+		for _, ip := range result.Credited() {
+			if name, ok := room.nameOf(ip); ok {
+				scores[name] += rules.ResponderPoints
+			}
+		}
+
+		for _, forgery := range result.Forgeries {
+			if name, ok := room.nameOf(forgery.Spoofer); ok && forgery.Accepted {
+				scores[name] += rules.SpoofPoints
+			}
+		}
+
+		if sniffer, ok := result.Sniffed(); ok {
+			if name, ok := room.nameOf(sniffer); ok {
+				scores[name] += rules.SniffPoints
+			}
+		}
+	}
+
+	return scores
+}
+
+// UpdateScoreboard recomputes the public scoreboard
+func (room *Room) UpdateScoreboard() {
+	room.State.Scoreboard = room.Scores()
+	room.State.Badges = room.BadgeIcons()
+
+	// In team mode scores are also totalled per subnet
+	if room.Options.TeamMode {
+		room.State.Teams = room.TeamScores(room.State.Scoreboard)
+	} else {
+		room.State.Teams = nil
+	}
+}
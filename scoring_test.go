@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSenderPoints(t *testing.T) {
+	created := time.Now()
+	rules := ScoringRules{
+		CorrectPoints:   2,
+		WrongPenalty:    1,
+		TimeBonus:       10,
+		TimeBonusWindow: 10,
+		CatchPoints:     3,
+		VerifyCost:      1,
+	}
+
+	tests := []struct {
+		name   string
+		result ChallengeResult
+		want   int
+	}{
+		{"unanswered", ChallengeResult{Created: created}, 0},
+		{"wrong answers", ChallengeResult{Created: created, Wrong: 3}, -3},
+		{"instant answer", ChallengeResult{Created: created, Answered: created, Correct: true}, 12},
+		{"half way through the bonus", ChallengeResult{Created: created, Answered: created.Add(5 * time.Second), Correct: true}, 7},
+		{"after the bonus", ChallengeResult{Created: created, Answered: created.Add(time.Minute), Correct: true}, 2},
+		{"correct after mistakes", ChallengeResult{Created: created, Answered: created.Add(time.Minute), Correct: true, Wrong: 2}, 0},
+		{"group", ChallengeResult{Created: created, Answered: created.Add(time.Minute), Correct: true, Recipients: []IP{{1, 1}, {1, 2}, {1, 3}}, Responders: []IP{{1, 1}, {1, 2}}}, 4},
+		{"verified and caught", ChallengeResult{Created: created, Verifications: 2, Forgeries: []Forgery{{Caught: true}, {}}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.SenderPoints(tt.result); got != tt.want {
+				t.Errorf("SenderPoints = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityScores(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ChallengeResult
+		want   int
+	}{
+		{"priority earnings are multiplied", ChallengeResult{Correct: true, Priority: true}, 3},
+		{"priority penalties are not", ChallengeResult{Wrong: 2, Priority: true}, -2},
+		{"normal packet", ChallengeResult{Correct: true}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.Scoring.WrongPenalty = 1
+				opts.PowerUps.PriorityMultiplier = 3
+			})
+			sender := room.players[0]
+			room.Challenges = map[Challenge]ChallengeResult{
+				{DestIP: room.ip(room.players[1]).String(), SourceIP: room.ip(sender).String()}: tt.result,
+			}
+			if got := room.Scores()[sender.Name]; got != tt.want {
+				t.Errorf("score = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		answers []string
+		want    int
+	}{
+		{"unlimited", 0, []string{"x", "y", "z"}, 3},
+		{"wrong answers run out", 2, []string{"x", "y", "z"}, 2},
+		{"correct after a mistake", 2, []string{"x", "AAAA"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, func(opts *RoomOptions) {
+				opts.Scoring.MaxAttempts = tt.limit
+			})
+			sender := room.players[0]
+			challenge := Challenge{ID: 1, DestIP: room.ip(room.players[1]).String(), SourceIP: room.ip(sender).String(), Question: "QQQQ", Answer: "AAAA"}
+			room.Challenges[challenge] = ChallengeResult{Created: time.Now()}
+
+			for _, answer := range tt.answers {
+				room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: answer})
+			}
+			if got := room.result(challenge).Attempts; got != tt.want {
+				t.Errorf("Attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxAttemptsGroup(t *testing.T) {
+	room := newTestRoom(t, 4, func(opts *RoomOptions) {
+		opts.Scoring.MaxAttempts = 1
+		opts.GroupDelivery = DeliverAll
+	})
+	sender, recipients := room.players[0], room.players[1:]
+
+	challenge := Challenge{ID: 1, DestIP: "255.255.255.255", SourceIP: room.ip(sender).String(), Question: "QQQQ", Answer: "AAAA"}
+	result := ChallengeResult{Created: time.Now()}
+	for _, client := range recipients {
+		result.Recipients = append(result.Recipients, room.ip(client))
+	}
+	room.Challenges[challenge] = result
+
+	// Every recipient's correct reply is accepted, even though only a single wrong answer is allowed
+	for _, client := range recipients {
+		room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: "AAAA", ResponderToken: room.Tokens[client.Name][0]})
+	}
+	if got := len(room.result(challenge).Responders); got != len(recipients) {
+		t.Errorf("%d responders, want %d (errors: %v)", got, len(recipients), room.failures(sender))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Sniffing
+//
+// Every subnet is a shared medium. Sniffers are given a copy of every challenge,
+// reply and TCP data segment sent to or from their subnet. If they submit a
+// challenge's answer before its sender does, they score.
+
+// SniffAttempt is a sniffer's attempt at answering an intercepted challenge
+type SniffAttempt struct {
+	// The sniffer
+	Sniffer IP `json:"sniffer"`
+
+	// If the sniffer submitted the right answer
+	Correct bool `json:"correct"`
+}
+
+// Sniffed returns the sniffer that answered the challenge first (if any)
+func (result ChallengeResult) Sniffed() (IP, bool) {
+	for _, sniff := range result.Sniffs {
+		if sniff.Correct {
+			return sniff.Sniffer, true
+		}
+	}
+	return IP{}, false
+}
+
+// Sniffers returns every sniffer that can see traffic between a and b
+//
+// The caller must hold the room's lock
+func (room *Room) Sniffers(a, b IP) []*Client {
+	var sniffers []*Client
+	for _, client := range room.WithRole(SnifferRole, a, b) {
+		ip, ok := room.Metadata.IPAddresses[client.Name]
+		if ok && (ip.Subnet == a.Subnet || ip.Subnet == b.Subnet) {
+			sniffers = append(sniffers, client)
+		}
+	}
+	return sniffers
+}
+
+// SubmitSniff is called to handle a Sniff message, answering an intercepted challenge
+func (room *Room) SubmitSniff(client *Client, msg SniffMessage) {
+	room.Lock()
+	if room.State.State != Running {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Sniffed answers can only be submitted while the game is running (state: %d)", room.State.State)))
+		room.Unlock()
+		return
+	}
+
+	if room.Roles[client.Name] != SnifferRole {
+		_ = client.Send(NewError("NOT_A_SNIFFER: Only sniffers can submit sniffed answers"))
+		room.Unlock()
+		return
+	}
+
+	challenge, result, ok := room.FindChallenge(msg.Destination, msg.Source, msg.Question)
+	if !ok || !result.Outstanding() {
+		_ = client.Send(NewError(fmt.Sprintf("NO_CHALLENGE: %s is not waiting on a reply from %s", msg.Source, msg.Destination)))
+		room.Unlock()
+		return
+	}
+
+	// Only traffic on the sniffer's own subnet can be seen
+	ip := room.Metadata.IPAddresses[client.Name]
+	source, _ := ParseIP(challenge.SourceIP)
+	dest, _ := ParseIP(challenge.DestIP)
+	if ip.Subnet != source.Subnet && ip.Subnet != dest.Subnet {
+		_ = client.Send(NewError(fmt.Sprintf("NOT_ON_SUBNET: Traffic between %s and %s never crosses your subnet", source, dest)))
+		room.Unlock()
+		return
+	}
+
+	// Each sniffer gets one guess, and only the first right answer counts
+	if _, ok := result.Sniffed(); ok {
+		_ = client.Send(NewError(fmt.Sprintf("ALREADY_SNIFFED: Someone already sniffed the answer to %q", msg.Question)))
+		room.Unlock()
+		return
+	}
+	for _, sniff := range result.Sniffs {
+		if sniff.Sniffer == ip {
+			_ = client.Send(NewError(fmt.Sprintf("ALREADY_GUESSED: You already submitted an answer to %q", msg.Question)))
+			room.Unlock()
+			return
+		}
+	}
+
+	correct := result.Matches(challenge, msg.Answer)
+	result.Sniffs = append(result.Sniffs, SniffAttempt{
+		Sniffer: ip,
+		Correct: correct,
+	})
+	room.record(challenge, result)
+	room.UpdateScoreboard()
+
+	_ = client.Send(NewGradeMessage(msg.Destination, msg.Question, correct, 0, false))
+	room.Unlock()
+
+	room.SendUserdata(client)
+	room.BroadcastGameState()
+}
+
+// Reply is called to handle a Reply message, delivering a destination's reply to the challenge's sender
+//
+// Replies cross the same subnets as the question did, so sniffers see them whatever the transport is
+func (room *Room) Reply(client *Client, msg ReplyMessage) {
+	room.Lock()
+	if room.State.State != Running {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Replies can only be sent while the game is running (state: %d)", room.State.State)))
+		room.Unlock()
+		return
+	}
+
+	ip, ok := room.address(client)
+	if !ok {
+		room.Unlock()
+		return
+	}
+	_, result, ok := room.FindChallenge(ip.String(), msg.Source, msg.Question)
+	if !ok || !result.Outstanding() {
+		_ = client.Send(NewError(fmt.Sprintf("NO_CHALLENGE: %s is not waiting on a reply from you", msg.Source)))
+		room.Unlock()
+		return
+	}
+
+	source, _ := ParseIP(msg.Source)
+	if !room.Metadata.Network.Reachable(ip, source) {
+		_ = client.Send(NewError(fmt.Sprintf("UNREACHABLE: %s can't be reached from %s", source, ip)))
+		room.Unlock()
+		return
+	}
+
+	sender := room.ClientByIP(source)
+	sniffers := room.Sniffers(ip, source)
+	room.Unlock()
+
+	if sender != nil {
+		_ = sender.Send(NewRepliedMessage(ip.String(), msg))
+	}
+	intercepted := NewInterceptedReplyMessage(ip.String(), msg.Source, msg.Question, msg.Answer)
+	for _, sniffer := range sniffers {
+		_ = sniffer.Send(intercepted)
+	}
+}
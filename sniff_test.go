@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSniffRoom creates a room where player 0 (subnet 1) is waiting on a reply from player 1
+// (subnet 2). Player 4 sniffs subnet 1, and player 2 sniffs subnet 3
+func newSniffRoom(t *testing.T) (*testRoom, Challenge) {
+	t.Helper()
+
+	room := newTestRoom(t, 5, nil)
+	room.Roles[room.players[4].Name] = SnifferRole
+	room.Roles[room.players[2].Name] = SnifferRole
+
+	challenge := Challenge{ID: 1, DestIP: room.ip(room.players[1]).String(), SourceIP: room.ip(room.players[0]).String(), Question: "QQQQ", Answer: "AAAA"}
+	room.Challenges[challenge] = ChallengeResult{Created: time.Now()}
+	return room, challenge
+}
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		tcp     bool
+		replier int
+		want    string
+	}{
+		{"datagram reply", false, 1, ""},
+		{"reply in tcp mode", true, 1, ""},
+		{"not the destination", false, 3, "NO_CHALLENGE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, challenge := newSniffRoom(t)
+			room.Options.TCP = tt.tcp
+			sender, replier := room.players[0], room.players[tt.replier]
+			near, far := room.players[4], room.players[2]
+
+			room.Reply(replier, ReplyMessage{Source: challenge.SourceIP, Question: challenge.Question, Answer: "AAAA", ResponderToken: "TOKEN"})
+
+			errs := room.failures(replier)
+			if tt.want != "" {
+				if len(errs) != 1 || !strings.HasPrefix(errs[0], tt.want) {
+					t.Errorf("errors = %v, want %q", errs, tt.want)
+				}
+				if len(room.received(sender, Replied)) != 0 || len(room.received(near, Intercepted)) != 0 {
+					t.Errorf("a refused reply was delivered")
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Fatalf("errors = %v", errs)
+			}
+
+			replies := room.received(sender, Replied)
+			if len(replies) != 1 {
+				t.Fatalf("sender got %d replies, want 1", len(replies))
+			}
+			var reply RepliedMessage
+			_ = json.Unmarshal(replies[0], &reply)
+			if reply.Source != challenge.DestIP || reply.Answer != "AAAA" || reply.ResponderToken != "TOKEN" {
+				t.Errorf("reply = %+v", reply)
+			}
+
+			// Only the sniffer on a subnet the reply crosses sees it, and never the token
+			intercepted := room.received(near, Intercepted)
+			if len(intercepted) != 1 {
+				t.Fatalf("sniffer on the sender's subnet saw %d replies, want 1", len(intercepted))
+			}
+			var seen InterceptedMessage
+			_ = json.Unmarshal(intercepted[0], &seen)
+			if seen.Answer != "AAAA" || seen.Source != challenge.DestIP || seen.Destination != challenge.SourceIP {
+				t.Errorf("intercepted = %+v", seen)
+			}
+			if strings.Contains(string(intercepted[0]), "TOKEN") {
+				t.Errorf("sniffer saw the responder token")
+			}
+			if n := len(room.received(far, Intercepted)); n != 0 {
+				t.Errorf("sniffer on another subnet saw %d replies", n)
+			}
+		})
+	}
+}
+
+func TestSubmitSniff(t *testing.T) {
+	tests := []struct {
+		name    string
+		sniffer int
+		// Answers submitted before the sniffer's, by player 4
+		before []string
+		answer string
+		want   string
+	}{
+		{"right answer", 4, nil, "AAAA", ""},
+		{"wrong answer", 4, nil, "BBBB", ""},
+		{"honest players can't sniff", 3, nil, "AAAA", "NOT_A_SNIFFER"},
+		{"traffic on another subnet", 2, nil, "AAAA", "NOT_ON_SUBNET"},
+		{"one guess each", 4, []string{"BBBB"}, "AAAA", "ALREADY_GUESSED"},
+		{"already sniffed", 4, []string{"AAAA"}, "AAAA", "ALREADY_SNIFFED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, challenge := newSniffRoom(t)
+			sniffer := room.players[tt.sniffer]
+			msg := SniffMessage{Source: challenge.SourceIP, Destination: challenge.DestIP, Question: challenge.Question}
+
+			for _, answer := range tt.before {
+				msg.Answer = answer
+				room.SubmitSniff(room.players[4], msg)
+			}
+			room.discard()
+
+			msg.Answer = tt.answer
+			room.SubmitSniff(sniffer, msg)
+
+			errs := room.failures(sniffer)
+			if tt.want == "" && len(errs) > 0 || tt.want != "" && (len(errs) != 1 || !strings.HasPrefix(errs[0], tt.want)) {
+				t.Errorf("errors = %v, want %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffScores(t *testing.T) {
+	room, challenge := newSniffRoom(t)
+	room.Options.Scoring.SniffPoints = 2
+	sender, sniffer := room.players[0], room.players[4]
+
+	room.SubmitSniff(sniffer, SniffMessage{Source: challenge.SourceIP, Destination: challenge.DestIP, Question: challenge.Question, Answer: "AAAA"})
+	room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: "AAAA"})
+
+	// Once the sender has answered, there is nothing left to sniff
+	room.SubmitSniff(room.players[2], SniffMessage{Source: challenge.SourceIP, Destination: challenge.DestIP, Question: challenge.Question, Answer: "AAAA"})
+
+	scores := room.Scores()
+	if scores[sniffer.Name] != 2 || scores[room.players[2].Name] != 0 {
+		t.Errorf("scores = %v, want 2 points for the first sniffer only", scores)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Spoofing
+//
+// Spoofers are told about other players' challenges as they are created. They
+// can forge a reply to the sender, pretending to be the destination, and tell
+// the server what they claimed the answer was. If the sender submits the forged
+// answer the spoofer scores. Senders who are suspicious can verify a reply's
+// signature first, catching the spoofer and earning points of their own.
+
+// Forgery is a reply forged by a spoofer
+type Forgery struct {
+	// The spoofer that forged the reply
+	Spoofer IP `json:"spoofer"`
+
+	// The forged answer
+	Answer string `json:"answer"`
+
+	// The sender submitted the forged answer
+	Accepted bool `json:"accepted,omitempty"`
+
+	// The sender verified the reply, and caught the forgery
+	Caught bool `json:"caught,omitempty"`
+}
+
+// Forged returns the index of the uncaught forgery with the given answer (-1 if there is none)
+func (result ChallengeResult) Forged(answer string) int {
+	for i, forgery := range result.Forgeries {
+		if !forgery.Caught && !forgery.Accepted && NormalizeAnswer(forgery.Answer) == NormalizeAnswer(answer) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Spoof is called to handle a Spoof message, recording a forged reply
+func (room *Room) Spoof(client *Client, msg SpoofMessage) {
+	room.Lock()
+	defer room.Unlock()
+
+	if room.State.State != Running {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Replies can only be forged while the game is running (state: %d)", room.State.State)))
+		return
+	}
+
+	if room.Roles[client.Name] != SpooferRole {
+		_ = client.Send(NewError("NOT_A_SPOOFER: Only spoofers can forge replies"))
+		return
+	}
+
+	challenge, result, ok := room.FindChallenge(msg.Destination, msg.Source, msg.Question)
+	if !ok || !result.Outstanding() {
+		_ = client.Send(NewError(fmt.Sprintf("NO_CHALLENGE: %s is not waiting on a reply from %s", msg.Source, msg.Destination)))
+		return
+	}
+
+	// A spoofer only gets one forgery per challenge
+	ip, ok := room.address(client)
+	if !ok {
+		return
+	}
+	for _, forgery := range result.Forgeries {
+		if forgery.Spoofer == ip {
+			_ = client.Send(NewError(fmt.Sprintf("ALREADY_FORGED: You already forged a reply to %s", msg.Source)))
+			return
+		}
+	}
+
+	result.Forgeries = append(result.Forgeries, Forgery{
+		Spoofer: ip,
+		Answer:  msg.Answer,
+	})
+	room.record(challenge, result)
+}
+
+// Verify is called to handle a Verify message, checking a reply's signature
+//
+// A reply is signed with the responder token of whoever sent it. Only the real
+// destination (or a recipient of a group challenge) holds its tokens, so forged
+// replies fail verification. The answer itself is never checked, so verifying
+// can't be used to find out if an answer is right
+func (room *Room) Verify(client *Client, msg VerifyMessage) {
+	room.Lock()
+	if room.State.State != Running && room.State.State != Stopping {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Replies can only be verified while the room is running or stopping (state: %d)", room.State.State)))
+		room.Unlock()
+		return
+	}
+
+	// The destination may have been given as a hostname
+	destination := msg.Destination
+	if record, ok := room.LookupHostname(destination); ok {
+		destination = record.IP.String()
+	}
+
+	address, ok := room.address(client)
+	if !ok {
+		room.Unlock()
+		return
+	}
+	ip := address.String()
+	challenge, result, ok := room.FindChallenge(destination, ip, msg.Question)
+	if !ok {
+		_ = client.Send(NewError("Challenge doesn't exist"))
+		room.Unlock()
+		return
+	}
+
+	// Verifying is not free
+	result.Verifications++
+
+	// The reply is authentic if it was signed by the host it claims to be from
+	signer, signed := room.TokenOwner(msg.ResponderToken)
+	var authentic bool
+	if len(result.Recipients) > 0 {
+		authentic = signed && containsIP(result.Recipients, signer)
+	} else {
+		authentic = signed && signer.String() == challenge.DestIP
+	}
+
+	// The forgery is caught, and the spoofer is revealed
+	var spoofer *IP
+	if i := result.Forged(msg.Answer); !authentic && i >= 0 {
+		result.Forgeries[i].Caught = true
+		spoofer = &result.Forgeries[i].Spoofer
+	}
+	room.record(challenge, result)
+	room.UpdateScoreboard()
+
+	_ = client.Send(NewVerifiedMessage(msg.Destination, msg.Question, authentic, spoofer))
+	room.Unlock()
+
+	room.SendUserdata(client)
+	room.BroadcastGameState()
+}
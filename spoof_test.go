@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSpoofRoom creates a room where player 0 is waiting on a reply from player 1 and player 2 is a spoofer
+func newSpoofRoom(t *testing.T) (*testRoom, Challenge) {
+	t.Helper()
+
+	room := newTestRoom(t, 3, nil)
+	room.Roles[room.players[2].Name] = SpooferRole
+
+	challenge := Challenge{ID: 1, DestIP: room.ip(room.players[1]).String(), SourceIP: room.ip(room.players[0]).String(), Question: "QQQQ", Answer: "AAAA"}
+	room.Challenges[challenge] = ChallengeResult{Created: time.Now()}
+	return room, challenge
+}
+
+func TestSpoof(t *testing.T) {
+	tests := []struct {
+		name    string
+		spoofer int
+		source  int
+		twice   bool
+		want    string
+	}{
+		{"spoofer forges a reply", 2, 0, false, ""},
+		{"honest players can't forge", 1, 0, false, "NOT_A_SPOOFER"},
+		{"no such challenge", 2, 1, false, "NO_CHALLENGE"},
+		{"one forgery per challenge", 2, 0, true, "ALREADY_FORGED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, challenge := newSpoofRoom(t)
+			spoofer := room.players[tt.spoofer]
+			msg := SpoofMessage{
+				Source:      room.ip(room.players[tt.source]).String(),
+				Destination: challenge.DestIP,
+				Question:    challenge.Question,
+				Answer:      "FAKE",
+			}
+
+			room.Spoof(spoofer, msg)
+			if tt.twice {
+				room.Spoof(spoofer, msg)
+			}
+
+			errs := room.failures(spoofer)
+			if tt.want == "" && len(errs) > 0 || tt.want != "" && (len(errs) != 1 || !strings.HasPrefix(errs[0], tt.want)) {
+				t.Errorf("errors = %v, want %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// The answer the spoofer forges (empty for no forgery)
+		forged string
+		// Whose token signs the reply (-1 for none)
+		signer    int
+		answer    string
+		authentic bool
+		caught    bool
+	}{
+		{"signed by the destination", "", 1, "AAAA", true, false},
+		{"wrong answer signed by the destination", "", 1, "BBBB", true, false},
+		{"unsigned reply", "", -1, "AAAA", false, false},
+		{"forged reply", "FAKE", 2, "FAKE", false, true},
+		{"forged reply with the right answer", "AAAA", 2, "AAAA", false, true},
+		{"forged reply signed by someone else", "FAKE", 0, "FAKE", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, challenge := newSpoofRoom(t)
+			sender, spoofer := room.players[0], room.players[2]
+			if tt.forged != "" {
+				room.Spoof(spoofer, SpoofMessage{Source: challenge.SourceIP, Destination: challenge.DestIP, Question: challenge.Question, Answer: tt.forged})
+			}
+
+			msg := VerifyMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: tt.answer}
+			if tt.signer >= 0 {
+				msg.ResponderToken = room.Tokens[room.players[tt.signer].Name][0]
+			}
+			room.Verify(sender, msg)
+
+			replies := room.received(sender, Verified)
+			if len(replies) != 1 {
+				t.Fatalf("got %d verdicts, want 1 (errors: %v)", len(replies), room.failures(sender))
+			}
+			var verdict VerifiedMessage
+			_ = json.Unmarshal(replies[0], &verdict)
+			if verdict.Authentic != tt.authentic {
+				t.Errorf("Authentic = %v, want %v", verdict.Authentic, tt.authentic)
+			}
+			if caught := verdict.Spoofer != nil && *verdict.Spoofer == room.ip(spoofer); caught != tt.caught {
+				t.Errorf("spoofer caught = %v, want %v", caught, tt.caught)
+			}
+
+			result := room.result(challenge)
+			if result.Verifications != 1 || result.Correct {
+				t.Errorf("result = %+v, want a single verification and no answer", result)
+			}
+		})
+	}
+}
+
+func TestAcceptedForgery(t *testing.T) {
+	room, challenge := newSpoofRoom(t)
+	room.Options.Scoring.SpoofPoints = 2
+	sender, spoofer := room.players[0], room.players[2]
+
+	room.Spoof(spoofer, SpoofMessage{Source: challenge.SourceIP, Destination: challenge.DestIP, Question: challenge.Question, Answer: "FAKE"})
+	room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: "FAKE"})
+
+	if got := room.Scores()[spoofer.Name]; got != 2 {
+		t.Errorf("spoofer scored %d, want 2", got)
+	}
+	if result := room.result(challenge); !result.Forgeries[0].Accepted || result.Correct {
+		t.Errorf("result = %+v, want an accepted forgery", result)
+	}
+}
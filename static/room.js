@@ -22,6 +22,107 @@ function getCookie(cname) {
     return "";
 }
 
+function handle_progress(progress, goal) {
+    let bar = document.getElementById("progress");
+    bar.max = goal;
+    bar.value = Math.min(progress, goal);
+    bar.hidden = goal == 0;
+
+    let label = document.getElementById("progress-label");
+    label.innerText = goal > 0 ? progress + " / " + goal : progress + " answered";
+}
+
+function handle_scoreboard(scoreboard, badges) {
+    let table = document.getElementById("scoreboard");
+    table.innerHTML = "<tr><th>Player</th><th>Score</th><th>Badges</th></tr>";
+
+    let names = Object.keys(scoreboard);
+    names.sort(function (a, b) {
+        return scoreboard[b] - scoreboard[a];
+    });
+    for (let name of names) {
+        let row = document.createElement("tr");
+        row.innerHTML = "<td>" + name + "</td><td>" + scoreboard[name] + "</td><td>" + (badges[name] || []).join(" ") + "</td>";
+        table.appendChild(row);
+    }
+}
+
+function handle_storm(storm) {
+    document.getElementById("storm").hidden = !storm.active;
+}
+
+function handle_translated(payload) {
+    alert("A packet arrived through the NAT gateway. Reply to " + payload.source);
+}
+
+function handle_replied(payload) {
+    alert(payload.source + " replied to " + payload.question + ": " + payload.answer);
+}
+
+function handle_badge(badge) {
+    alert(badge.badge + " You earned " + badge.name + "!");
+}
+
+function handle_round_end(payload) {
+    let summary = payload.summary;
+
+    let title = document.getElementById("round-summary-title");
+    title.innerText = payload.final ? "Final results" : "Round " + summary.round + " results";
+
+    // show this round's score next to the running total, best total first
+    let table = document.getElementById("round-summary-table");
+    table.innerHTML = "<tr><th>Player</th><th>This round</th><th>Total</th></tr>";
+
+    let names = Object.keys(payload.standings);
+    names.sort(function (a, b) {
+        return payload.standings[b] - payload.standings[a];
+    });
+    for (let name of names) {
+        let row = document.createElement("tr");
+        row.innerHTML = "<td>" + name + "</td><td>" + (summary.scores[name] || 0) + "</td><td>" + payload.standings[name] + "</td>";
+        table.appendChild(row);
+    }
+
+    document.getElementById("round-summary").hidden = false;
+}
+
+function handle_restart(payload) {
+    document.getElementById("round-summary").hidden = true;
+    handle_progress(0, 0);
+}
+
+function handle_event(event) {
+    let descriptions = {
+        "subnet-down": "Subnet " + event.subnet + " is down",
+        "subnet-up": "Subnet " + event.subnet + " is back up",
+        "partition": "Subnets " + event.subnet + " and " + event.peer + " can't reach each other",
+        "heal": "Subnets " + event.subnet + " and " + event.peer + " can reach each other again",
+        "move": event.player + " was moved to subnet " + event.subnet,
+        "rotate": "Every Q/A table was replaced",
+    };
+
+    let item = document.createElement("li");
+    item.innerText = new Date(event.time).toLocaleTimeString() + " " + descriptions[event.kind];
+    document.getElementById("events").appendChild(item);
+}
+
+function handle_intercepted(payload) {
+    let table = document.getElementById("intercepted-table");
+    let row = document.createElement("tr");
+    let seen = payload.data || payload.question;
+    if (payload.answer) {
+        seen += " → " + payload.answer;
+    }
+    // Other students write the data and answers, so they are only ever shown as text
+    for (let text of [payload.source, payload.destination, seen]) {
+        let td = document.createElement("td");
+        td.appendChild(document.createTextNode(text));
+        row.appendChild(td);
+    }
+    table.appendChild(row);
+    document.getElementById("intercepted").hidden = false;
+}
+
 // 'num_subnets': int
 // 'subnets': map[int][int]string
 // 'ip_addresses': map[string]string
@@ -108,6 +209,43 @@ function handle_metadata(metadata) {
             }
         }
     }
+
+    handle_nat(metadata.nat);
+}
+
+// 'public_subnet': int
+// 'gateway': string
+// 'mappings': [{private_ip, private_port, public_ip, public_port}]
+function handle_nat(nat) {
+    let nat_table = document.getElementById("nat-table");
+    nat_table.innerHTML = "";
+
+    // NAT is disabled
+    if (nat == undefined) {
+        return;
+    }
+
+    // header row
+    let header_row = document.createElement("tr");
+    for (let header of ["Private Address", "Public Address"]) {
+        let th = document.createElement("th");
+        th.innerHTML = header;
+        header_row.appendChild(th);
+    }
+    nat_table.appendChild(header_row);
+
+    for (let mapping of nat.mappings) {
+        let row = document.createElement("tr");
+        for (let text of [
+            mapping.private_ip + ":" + mapping.private_port,
+            mapping.public_ip + ":" + mapping.public_port,
+        ]) {
+            let td = document.createElement("td");
+            td.appendChild(document.createTextNode(text));
+            row.appendChild(td);
+        }
+        nat_table.appendChild(row);
+    }
 }
 
 // Name Name `json:"name"`
@@ -136,6 +274,46 @@ function handle_userdata(userdata) {
     name_span.appendChild(name_node);
     whois.appendChild(name_span);
 
+    // difficulty level
+    let level_span = document.createElement("span");
+    level_span.className = "level";
+    level_span.innerText = " - level " + userdata.level;
+    whois.appendChild(level_span);
+
+    // shields that can refuse a challenge
+    if (userdata.shields) {
+        let shield_span = document.createElement("span");
+        shield_span.className = "shields";
+        shield_span.innerText = " - " + userdata.shields + " shield(s)";
+        whois.appendChild(shield_span);
+    }
+
+    // badges earned so far
+    if (userdata.badges) {
+        let badge_span = document.createElement("span");
+        badge_span.className = "badges";
+        badge_span.innerText = " " + userdata.badges.map(function (badge) {
+            return badge.badge;
+        }).join(" ");
+        whois.appendChild(badge_span);
+    }
+
+    // responder tokens, one of which goes with every reply
+    if (userdata.tokens) {
+        let token_span = document.createElement("span");
+        token_span.className = "tokens";
+        token_span.innerText = " - tokens: " + userdata.tokens.join(", ");
+        whois.appendChild(token_span);
+    }
+
+    // secret role (if any)
+    if (userdata.role) {
+        let role_span = document.createElement("span");
+        role_span.className = "role";
+        role_span.innerText = " - you are a " + userdata.role;
+        whois.appendChild(role_span);
+    }
+
     // fill out qa-table
     let qa_table = document.getElementById("qa-table");
     qa_table.innerHTML = "";
@@ -219,6 +397,41 @@ async function ws_connect() {
             case "Userdata":
                 handle_userdata(data.payload);
                 break;
+            case "GameState":
+                handle_progress(data.payload.progress || 0, data.payload.goal || 0);
+                handle_scoreboard(data.payload.scoreboard || {}, data.payload.badges || {});
+                break;
+            case "Storm":
+                handle_storm(data.payload);
+                break;
+            case "BadgeEarned":
+                handle_badge(data.payload);
+                break;
+            case "Progress":
+                handle_progress(data.payload.progress, data.payload.goal || 0);
+                break;
+            case "RoundEnd":
+                handle_round_end(data.payload);
+                break;
+            case "Restart":
+                handle_restart(data.payload);
+                break;
+            case "SubnetRequested":
+                document.getElementById("whois").appendChild(document.createTextNode(
+                    " - waiting for the host to approve subnet " + (data.payload.subnet || "(any)")));
+                break;
+            case "Event":
+                handle_event(data.payload);
+                break;
+            case "Intercepted":
+                handle_intercepted(data.payload);
+                break;
+            case "Translated":
+                handle_translated(data.payload);
+                break;
+            case "Replied":
+                handle_replied(data.payload);
+                break;
         }
     };
 
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TCPState is the state of a simulated TCP connection
+type TCPState string
+
+const (
+	SynSent     TCPState = "SYN_SENT"
+	SynReceived TCPState = "SYN_RECEIVED"
+	Established TCPState = "ESTABLISHED"
+	FinWait     TCPState = "FIN_WAIT"
+	Closed      TCPState = "CLOSED"
+)
+
+// The two ends of a connection
+const (
+	// The host that sent the SYN
+	initiator = 0
+	// The host that answered with a SYN-ACK
+	responder = 1
+)
+
+// ConnectionKey identifies a connection by its initiator and responder
+type ConnectionKey struct {
+	Client IP
+	Server IP
+}
+
+// TCPConnection tracks a single handshake / data / teardown exchange between two players
+type TCPConnection struct {
+	// The two ends of the connection
+	Client IP `json:"client"`
+	Server IP `json:"server"`
+
+	// The current state of the connection
+	State TCPState `json:"state"`
+
+	// The next sequence number expected from each end
+	Next [2]int `json:"next"`
+
+	// The data segments sent by each end, in order
+	Data [2][]string `json:"data"`
+
+	// If each end has sent a FIN
+	Fin [2]bool `json:"fin"`
+}
+
+// connection finds the connection between a and b in either direction, along with which end a is
+func (room *Room) connection(a, b IP) (*TCPConnection, int, bool) {
+	if conn, ok := room.Connections[ConnectionKey{a, b}]; ok {
+		return conn, initiator, true
+	}
+	if conn, ok := room.Connections[ConnectionKey{b, a}]; ok {
+		return conn, responder, true
+	}
+	return nil, 0, false
+}
+
+// Segment is called to handle the Syn, SynAck, Ack, Data and Fin messages
+//
+// Every segment must carry the sequence number the other end expects next and
+// acknowledge everything the other end has sent so far.
+func (room *Room) Segment(client *Client, kind MessageType, msg SegmentMessage) {
+	room.Lock()
+	if room.State.State != Running {
+		_ = client.Send(NewError(fmt.Sprintf("WRONG_STATE: Segments can only be sent while the game is running (state: %d)", room.State.State)))
+		room.Unlock()
+		return
+	}
+
+	if !room.Options.TCP {
+		_ = client.Send(NewError("TCP_DISABLED: This room does not use TCP"))
+		room.Unlock()
+		return
+	}
+
+	source, ok := room.Metadata.IPAddresses[client.Name]
+	if !ok {
+		_ = client.Send(NewError("NO_IP: Join a subnet before sending segments"))
+		room.Unlock()
+		return
+	}
+
+	dest, err := ParseIP(msg.Destination)
+	if err != nil {
+		_ = client.Send(NewError(fmt.Sprintf("INVALID_DESTINATION: %v", err)))
+		room.Unlock()
+		return
+	}
+
+	if !room.Metadata.Network.Reachable(source, dest) {
+		_ = client.Send(NewError(fmt.Sprintf("UNREACHABLE: %s can't be reached from %s", dest, source)))
+		room.Unlock()
+		return
+	}
+
+	conn, err := room.applySegment(kind, source, dest, msg)
+	if err != nil {
+		_ = client.Send(NewError(err.Error()))
+		room.Unlock()
+		return
+	}
+
+	// Both ends learn about the new connection state
+	update := NewConnectionStateMessage(*conn)
+	_ = client.Send(update)
+	if peer := room.ClientByIP(dest); peer != nil {
+		_ = peer.Send(update)
+	}
+
+	// Data crossing a shared subnet can be sniffed
+	var sniffers []*Client
+	if kind == Data {
+		sniffers = room.Sniffers(source, dest)
+	}
+	room.Unlock()
+
+	for _, sniffer := range sniffers {
+		_ = sniffer.Send(NewInterceptedSegmentMessage(source, dest, msg.Data))
+	}
+}
+
+// applySegment validates a segment against the connection's state and advances it
+func (room *Room) applySegment(kind MessageType, source, dest IP, msg SegmentMessage) (*TCPConnection, error) {
+	if kind == Syn {
+		if conn, _, ok := room.connection(source, dest); ok && conn.State != Closed {
+			return nil, fmt.Errorf("CONNECTION_EXISTS: A connection to %s is already %s", dest, conn.State)
+		}
+
+		conn := &TCPConnection{
+			Client: source,
+			Server: dest,
+			State:  SynSent,
+			Data:   [2][]string{{}, {}},
+		}
+		conn.Next[initiator] = msg.Seq + 1
+		delete(room.Connections, ConnectionKey{dest, source})
+		room.Connections[ConnectionKey{source, dest}] = conn
+		return conn, nil
+	}
+
+	conn, end, ok := room.connection(source, dest)
+	if !ok {
+		return nil, fmt.Errorf("NO_CONNECTION: There is no connection to %s, send a SYN first", dest)
+	}
+	other := 1 - end
+
+	// A SYN-ACK chooses the responder's initial sequence number
+	if kind == SynAck {
+		if end != responder || conn.State != SynSent {
+			return nil, fmt.Errorf("UNEXPECTED_SEGMENT: SYN-ACK is not valid while the connection is %s", conn.State)
+		}
+		if msg.Ack != conn.Next[initiator] {
+			return nil, fmt.Errorf("BAD_ACK: Expected ack %d got %d", conn.Next[initiator], msg.Ack)
+		}
+		conn.Next[responder] = msg.Seq + 1
+		conn.State = SynReceived
+		return conn, nil
+	}
+
+	// Every other segment has to be exactly in order
+	if msg.Seq != conn.Next[end] {
+		return nil, fmt.Errorf("OUT_OF_ORDER: Expected seq %d got %d", conn.Next[end], msg.Seq)
+	}
+	if msg.Ack != conn.Next[other] {
+		return nil, fmt.Errorf("BAD_ACK: Expected ack %d got %d", conn.Next[other], msg.Ack)
+	}
+
+	switch kind {
+	case Ack:
+		if end != initiator || conn.State != SynReceived {
+			return nil, fmt.Errorf("UNEXPECTED_SEGMENT: ACK is not valid while the connection is %s", conn.State)
+		}
+		conn.State = Established
+	case Data:
+		if conn.State != Established || conn.Fin[end] {
+			return nil, fmt.Errorf("UNEXPECTED_SEGMENT: DATA is not valid while the connection is %s", conn.State)
+		}
+		if msg.Data == "" {
+			return nil, fmt.Errorf("EMPTY_SEGMENT: DATA segments must carry data")
+		}
+		conn.Data[end] = append(conn.Data[end], msg.Data)
+		// Sequence numbers count symbols, like fragment offsets, not bytes
+		conn.Next[end] += utf8.RuneCountInString(msg.Data)
+	case Fin:
+		if (conn.State != Established && conn.State != FinWait) || conn.Fin[end] {
+			return nil, fmt.Errorf("UNEXPECTED_SEGMENT: FIN is not valid while the connection is %s", conn.State)
+		}
+		conn.Fin[end] = true
+		conn.Next[end]++
+		if conn.Fin[other] {
+			conn.State = Closed
+		} else {
+			conn.State = FinWait
+		}
+	}
+
+	return conn, nil
+}
+
+// DeliveredOverTCP returns true if the challenge's question and answer were carried by a connection
+// initiated by the challenge's source
+func (room *Room) DeliveredOverTCP(challenge Challenge, result ChallengeResult) bool {
+	source, err := ParseIP(challenge.SourceIP)
+	if err != nil {
+		return false
+	}
+	dest, err := ParseIP(challenge.DestIP)
+	if err != nil {
+		return false
+	}
+
+	conn, ok := room.Connections[ConnectionKey{source, dest}]
+	if !ok {
+		return false
+	}
+
+	return strings.Contains(strings.Join(conn.Data[initiator], ""), result.Transmitted(challenge)) &&
+		strings.Contains(strings.Join(conn.Data[responder], ""), challenge.Answer)
+}
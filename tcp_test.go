@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplySegment(t *testing.T) {
+	client, server := IP{1, 1}, IP{2, 1}
+	type segment struct {
+		kind     MessageType
+		from, to IP
+		seq, ack int
+		data     string
+	}
+
+	tests := []struct {
+		name     string
+		segments []segment
+		wantErr  string
+		want     TCPState
+	}{
+		{"handshake", []segment{
+			{Syn, client, server, 100, 0, ""},
+			{SynAck, server, client, 300, 101, ""},
+			{Ack, client, server, 101, 301, ""},
+		}, "", Established},
+		{"data before the handshake", []segment{
+			{Data, client, server, 100, 0, "hi"},
+		}, "NO_CONNECTION", ""},
+		{"bad ack in the SYN-ACK", []segment{
+			{Syn, client, server, 100, 0, ""},
+			{SynAck, server, client, 300, 100, ""},
+		}, "BAD_ACK", SynSent},
+		{"out of order data", []segment{
+			{Syn, client, server, 100, 0, ""},
+			{SynAck, server, client, 300, 101, ""},
+			{Ack, client, server, 101, 301, ""},
+			{Data, client, server, 102, 301, "hi"},
+		}, "OUT_OF_ORDER", Established},
+		{"teardown", []segment{
+			{Syn, client, server, 100, 0, ""},
+			{SynAck, server, client, 300, 101, ""},
+			{Ack, client, server, 101, 301, ""},
+			{Data, client, server, 101, 301, "hi"},
+			{Fin, client, server, 103, 301, ""},
+			{Fin, server, client, 301, 104, ""},
+		}, "", Closed},
+		{"sequence numbers count symbols, not bytes", []segment{
+			{Syn, client, server, 100, 0, ""},
+			{SynAck, server, client, 300, 101, ""},
+			{Ack, client, server, 101, 301, ""},
+			{Data, client, server, 101, 301, "🍎🍌"},
+			{Fin, client, server, 103, 301, ""},
+			{Fin, server, client, 301, 104, ""},
+		}, "", Closed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := NewRoom("TEST")
+			var err error
+			for _, s := range tt.segments {
+				if _, err = room.applySegment(s.kind, s.from, s.to, SegmentMessage{Destination: s.to.String(), Seq: s.seq, Ack: s.ack, Data: s.data}); err != nil {
+					break
+				}
+			}
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("applySegment() = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Fatalf("applySegment() = %v, want %s", err, tt.wantErr)
+			}
+			if conn, _, ok := room.connection(client, server); ok && conn.State != tt.want {
+				t.Errorf("connection is %s, want %s", conn.State, tt.want)
+			}
+		})
+	}
+}
+
+func TestUndeliveredAnswers(t *testing.T) {
+	room := newTestRoom(t, 2, func(opts *RoomOptions) {
+		opts.TCP = true
+		opts.Scoring.WrongPenalty = 1
+	})
+	sender := room.players[0]
+	challenge, _ := room.request(t, sender)
+
+	// Answers sent without a connection are never delivered, right or wrong
+	tests := []struct {
+		name      string
+		answer    string
+		attempts  int
+		wrong     int
+		wantScore int
+	}{
+		{"wrong answer", challenge.Answer + "X", 1, 1, -1},
+		{"right answer", challenge.Answer, 2, 1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: tt.answer})
+
+			if errs := room.failures(sender); len(errs) == 0 || !strings.HasPrefix(errs[0], "NOT_DELIVERED") {
+				t.Errorf("errors = %v, want NOT_DELIVERED", errs)
+			}
+			result := room.result(challenge)
+			if result.Correct || result.Attempts != tt.attempts || result.Wrong != tt.wrong {
+				t.Errorf("correct %v, %d attempts, %d wrong; want %d attempts, %d wrong", result.Correct, result.Attempts, result.Wrong, tt.attempts, tt.wrong)
+			}
+			if got := room.Scores()[sender.Name]; got != tt.wantScore {
+				t.Errorf("score = %d, want %d", got, tt.wantScore)
+			}
+		})
+	}
+}
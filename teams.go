@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// The scopes a team mode room can restrict challenges to
+const (
+	// Challenges may go anywhere
+	AnyScope = "any"
+	// Challenges stay within the sender's subnet
+	IntraScope = "intra"
+	// Challenges always leave the sender's subnet
+	InterScope = "inter"
+)
+
+// TeamScore is a subnet's total score, and how much each of its members contributed
+type TeamScore struct {
+	// The team's subnet
+	Subnet int `json:"subnet"`
+
+	// The team's name
+	Name string `json:"name"`
+
+	// The sum of every member's score
+	Total int `json:"total"`
+
+	// The score of every member
+	Members map[Name]int `json:"members"`
+}
+
+// TeamName returns the name of a subnet's team
+func (room *Room) TeamName(subnet int) string {
+	if name, ok := room.Metadata.Teams[subnet]; ok {
+		return name
+	}
+	return fmt.Sprintf("192.168.%d.0/24", subnet)
+}
+
+// InScope returns true if a challenge from source may be sent to dest
+func (room *Room) InScope(source, dest IP) bool {
+	if !room.Options.TeamMode {
+		return true
+	}
+
+	switch room.Options.TeamScope {
+	case IntraScope:
+		return source.Subnet == dest.Subnet
+	case InterScope:
+		return source.Subnet != dest.Subnet
+	}
+	return true
+}
+
+// inScope returns the hosts of ips a challenge from source may be sent to
+func (room *Room) inScope(source IP, ips []IP) []IP {
+	var result []IP
+	for _, ip := range ips {
+		if room.InScope(source, ip) {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
+
+// TeamScores aggregates player scores by subnet, ordered from highest to lowest
+func (room *Room) TeamScores(scores map[Name]int) []TeamScore {
+	teams := make([]TeamScore, 0, room.Metadata.NumSubnets)
+	for subnet := 1; subnet <= room.Metadata.NumSubnets; subnet++ {
+		team := TeamScore{
+			Subnet:  subnet,
+			Name:    room.TeamName(subnet),
+			Members: make(map[Name]int),
+		}
+		for _, name := range room.Metadata.Subnets[subnet] {
+			team.Members[name] = scores[name]
+			team.Total += scores[name]
+		}
+		teams = append(teams, team)
+	}
+
+	sort.SliceStable(teams, func(i, j int) bool {
+		return teams[i].Total > teams[j].Total
+	})
+	return teams
+}
+
+// RenameTeam changes the name of a subnet's team
+func (room *Room) RenameTeam(subnet int, name string) error {
+	name = strings.TrimSpace(name)
+
+	room.Lock()
+	if subnet <= 0 || subnet > room.Metadata.NumSubnets {
+		room.Unlock()
+		return fmt.Errorf("subnet %d does not exist", subnet)
+	}
+	if name == "" || len(name) > 32 {
+		room.Unlock()
+		return fmt.Errorf("team names must be between 1 and 32 characters")
+	}
+
+	room.Metadata.Teams[subnet] = name
+	room.UpdateScoreboard()
+	room.Unlock()
+
+	room.BroadcastMetadata()
+	room.BroadcastGameState()
+	return nil
+}
+
+// RenameTeamHandler lets the host rename a team at any time
+// /room/{code}/teams/{subnet}?name=<name>&key=<key>
+func RenameTeamHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := vars["code"]
+	if code == "" {
+		http.Error(w, "Missing room code", http.StatusBadRequest)
+		return
+	}
+
+	subnet, err := strconv.Atoi(vars["subnet"])
+	if err != nil {
+		http.Error(w, "Invalid subnet", http.StatusBadRequest)
+		return
+	}
+
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeHost(w, r, room) {
+		return
+	}
+
+	if err := room.RenameTeam(subnet, r.FormValue("name")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestInScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		teamMode bool
+		scope    string
+		dest     IP
+		want     bool
+	}{
+		{"not in team mode", false, IntraScope, IP{2, 1}, true},
+		{"any scope", true, AnyScope, IP{2, 1}, true},
+		{"intra, same subnet", true, IntraScope, IP{1, 2}, true},
+		{"intra, other subnet", true, IntraScope, IP{2, 1}, false},
+		{"inter, same subnet", true, InterScope, IP{1, 2}, false},
+		{"inter, other subnet", true, InterScope, IP{2, 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := NewRoom("TEST")
+			room.Options.TeamMode = tt.teamMode
+			room.Options.TeamScope = tt.scope
+			if got := room.InScope(IP{1, 1}, tt.dest); got != tt.want {
+				t.Errorf("InScope = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseGroupScope(t *testing.T) {
+	// Player i is in subnet i%4+1, so players 0 and 4 share subnet 1
+	tests := []struct {
+		name      string
+		broadcast bool
+		scope     string
+		want      []int
+	}{
+		{"broadcast, any scope", true, AnyScope, []int{4}},
+		{"broadcast, intra", true, IntraScope, []int{4}},
+		{"broadcast, inter", true, InterScope, nil},
+		{"multicast, any scope", false, AnyScope, []int{1, 4, 5}},
+		{"multicast, intra", false, IntraScope, []int{4}},
+		{"multicast, inter", false, InterScope, []int{1, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 6, func(opts *RoomOptions) {
+				opts.TeamMode = true
+				opts.TeamScope = tt.scope
+			})
+			if tt.broadcast {
+				room.Options.BroadcastChance = 1
+			} else {
+				room.Options.MulticastChance = 1
+				room.Options.MulticastGroups = []MulticastGroup{{Address: "224.0.0.1"}}
+				for _, i := range []int{0, 1, 4, 5} {
+					room.Options.MulticastGroups[0].Members = append(room.Options.MulticastGroups[0].Members, room.ip(room.players[i]))
+				}
+			}
+
+			_, recipients := room.ChooseGroup(room.ip(room.players[0]))
+
+			var got, want []string
+			for _, ip := range recipients {
+				got = append(got, ip.String())
+			}
+			for _, i := range tt.want {
+				want = append(want, room.ip(room.players[i]).String())
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("recipients = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTeamScores(t *testing.T) {
+	room := newTestRoom(t, 4, func(opts *RoomOptions) {
+		opts.TeamMode = true
+	})
+	scores := map[Name]int{
+		room.players[0].Name: 1,
+		room.players[1].Name: 5,
+		room.players[3].Name: 2,
+	}
+	if err := room.RenameTeam(2, "  Routers "); err != nil {
+		t.Fatalf("RenameTeam: %v", err)
+	}
+
+	teams := room.TeamScores(scores)
+	wantSubnets := []int{2, 4, 1, 3}
+	for i, team := range teams {
+		if team.Subnet != wantSubnets[i] {
+			t.Fatalf("team %d is subnet %d, want %d", i, team.Subnet, wantSubnets[i])
+		}
+	}
+	if teams[0].Name != "Routers" || teams[0].Total != 5 {
+		t.Errorf("top team = %+v, want Routers with 5 points", teams[0])
+	}
+	if teams[3].Name != "192.168.3.0/24" {
+		t.Errorf("unnamed team is called %q", teams[3].Name)
+	}
+}
+
+func TestRenameTeam(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnet  int
+		team    string
+		wantErr bool
+	}{
+		{"valid", 1, "Blue", false},
+		{"missing subnet", 9, "Blue", true},
+		{"blank name", 1, "   ", true},
+		{"long name", 1, strings.Repeat("x", 33), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 1, nil)
+			if err := room.RenameTeam(tt.subnet, tt.team); (err != nil) != tt.wantErr {
+				t.Errorf("RenameTeam error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenameTeamHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		host   bool
+		status int
+	}{
+		{"host renames a team", http.MethodPost, true, http.StatusOK},
+		{"without the host key", http.MethodPost, false, http.StatusForbidden},
+		{"GET", http.MethodGet, true, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 1, nil)
+			w := room.serve(t, RenameTeamHandler, tt.method, tt.host, "name=Routers", "", map[string]string{"subnet": "1"})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if renamed := room.Metadata.Teams[1] == "Routers"; renamed != (tt.status == http.StatusOK) {
+				t.Errorf("team renamed: %v", renamed)
+			}
+		})
+	}
+}
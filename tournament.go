@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	ErrNoTournament    = errors.New("the room is not running a tournament")
+	ErrRoundInProgress = errors.New("the next round can only start once the current round has stopped")
+	ErrTournamentOver  = errors.New("every round of the tournament has been played")
+)
+
+// Tournament tracks a game played over several rounds
+type Tournament struct {
+	// The current round (starting at 1)
+	Round int `json:"round"`
+
+	// The number of rounds in the tournament
+	Rounds int `json:"rounds"`
+
+	// Each player's total score over every finished round
+	Standings map[Name]int `json:"standings"`
+
+	// The results of every finished round
+	Summaries []RoundSummary `json:"summaries"`
+}
+
+// RoundSummary is the result of a single round
+type RoundSummary struct {
+	// The round that finished
+	Round int `json:"round"`
+
+	// Each player's score in this round
+	Scores map[Name]int `json:"scores"`
+
+	// Each team's score in this round (only in team mode)
+	Teams []TeamScore `json:"teams,omitempty"`
+
+	// The player with the highest score in this round
+	Winner Name `json:"winner"`
+
+	// The network events the host injected during this round, in order
+	Events []NetworkEvent `json:"events,omitempty"`
+}
+
+// withRules returns a copy of the options with a round's rules applied on top
+//
+// A round's rules can't change the shape of the tournament itself
+func (opts RoomOptions) withRules(rules json.RawMessage) (RoomOptions, error) {
+	next, err := opts.clone()
+	if err != nil {
+		return next, err
+	}
+
+	if len(rules) > 0 {
+		if err := json.Unmarshal(rules, &next); err != nil {
+			return next, err
+		}
+	}
+	next.Rounds, next.RoundRules = opts.Rounds, opts.RoundRules
+	return next, nil
+}
+
+// validateRounds checks the tournament options and every round's rules
+func (opts RoomOptions) validateRounds(numSubnets int) error {
+	if opts.Rounds < 0 {
+		return fmt.Errorf("rounds must not be negative (got %d)", opts.Rounds)
+	}
+	if len(opts.RoundRules) > 0 && len(opts.RoundRules) >= opts.Rounds {
+		return fmt.Errorf("there are rules for %d later rounds but only %d rounds", len(opts.RoundRules), opts.Rounds)
+	}
+
+	for i, rules := range opts.RoundRules {
+		next, err := opts.withRules(rules)
+		if err != nil {
+			return fmt.Errorf("invalid rules for round %d: %w", i+2, err)
+		}
+
+		next.RoundRules = nil
+		if err := next.Validate(numSubnets); err != nil {
+			return fmt.Errorf("invalid rules for round %d: %w", i+2, err)
+		}
+	}
+	return nil
+}
+
+// BeginRound starts tracking a tournament when its first round starts
+//
+// The caller must hold the room's lock
+func (room *Room) BeginRound() {
+	if room.Options.Rounds > 1 && room.State.Tournament == nil {
+		room.State.Tournament = &Tournament{
+			Round:     1,
+			Rounds:    room.Options.Rounds,
+			Standings: map[Name]int{},
+			Summaries: []RoundSummary{},
+		}
+	}
+}
+
+// EndRound records the results of the round that just stopped, returning the summary to broadcast
+//
+// The caller must hold the room's lock
+func (room *Room) EndRound() *Message {
+	tournament := room.State.Tournament
+	if tournament == nil {
+		return nil
+	}
+
+	room.UpdateScoreboard()
+	summary := RoundSummary{
+		Round:  tournament.Round,
+		Scores: room.State.Scoreboard,
+		Teams:  room.State.Teams,
+		Events: room.State.Events,
+	}
+	for name, score := range summary.Scores {
+		tournament.Standings[name] += score
+		if summary.Winner == (Name{}) || score > summary.Scores[summary.Winner] {
+			summary.Winner = name
+		}
+	}
+	tournament.Summaries = append(tournament.Summaries, summary)
+
+	msg := NewRoundEndMessage(summary, tournament.Standings, tournament.Round >= tournament.Rounds)
+	return &msg
+}
+
+// shuffleSubnets spreads every player randomly and evenly across the subnets
+//
+// The caller must hold the room's lock
+func (room *Room) shuffleSubnets() {
+	names := make([]Name, 0, len(room.Metadata.IPAddresses))
+	for name := range room.Metadata.IPAddresses {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].String() < names[j].String()
+	})
+	rand.Shuffle(len(names), func(i, j int) {
+		names[i], names[j] = names[j], names[i]
+	})
+
+	for _, name := range names {
+		room.leaveSubnet(name)
+	}
+	for i, name := range names {
+		room.assignAddress(name, i%room.Metadata.NumSubnets+1)
+	}
+}
+
+// Restart resets the room for the next round of the tournament
+//
+// Every challenge is cleared, subnets are reshuffled (if enabled), Q/A tables and
+// keys are regenerated, and the round's rules are applied. The previous round's
+// events live on in its summary. The room goes back to Waiting so the host can
+// start the round.
+func (room *Room) Restart() error {
+	room.Lock()
+	tournament := room.State.Tournament
+	if tournament == nil {
+		room.Unlock()
+		return ErrNoTournament
+	}
+	if room.State.State != Stopped {
+		room.Unlock()
+		return ErrRoundInProgress
+	}
+	if tournament.Round >= tournament.Rounds {
+		room.Unlock()
+		return ErrTournamentOver
+	}
+
+	// The next round's rules are applied on top of the previous round's options
+	var rules json.RawMessage
+	if tournament.Round <= len(room.Options.RoundRules) {
+		rules = room.Options.RoundRules[tournament.Round-1]
+	}
+	opts, err := room.Options.withRules(rules)
+	if err != nil {
+		room.Unlock()
+		return err
+	}
+
+	// The rules are applied under the same lock, so the round can't start with the old
+	// ones. This also gives the NAT gateway a fresh table, as addresses may change
+	if _, _, err := room.setOptionsLocked(opts); err != nil {
+		room.Unlock()
+		return fmt.Errorf("failed to apply the rules for round %d: %w", tournament.Round+1, err)
+	}
+
+	tournament.Round++
+	room.State = PublicState{
+		State:      Waiting,
+		Goal:       room.Options.CoopGoal,
+		Tournament: tournament,
+	}
+	room.Challenges = make(map[Challenge]ChallengeResult)
+	room.forgetStats()
+	room.Connections = make(map[ConnectionKey]*TCPConnection)
+	room.Datagrams = make(map[DatagramKey]*Datagram)
+
+	// The network is repaired between rounds
+	room.Metadata.Network = NetworkState{
+		Down:       []int{},
+		Partitions: [][2]int{},
+	}
+	room.Retired = make(map[IP]Name)
+	room.Pending = make(map[Name]SubnetRequest)
+	room.ShieldsUsed = make(map[Name]int)
+	room.rules = RulesEngine{}
+
+	if room.Options.ShuffleSubnets {
+		room.shuffleSubnets()
+	}
+
+	room.RegenerateQATables()
+	for name := range room.QATables {
+		room.AssignKey(name)
+		room.AssignTokens(name)
+	}
+	room.UpdateScoreboard()
+
+	clients := make([]*Client, 0, len(room.Clients))
+	for _, client := range room.Clients {
+		clients = append(clients, client)
+	}
+	addresses := make(map[Name]IP, len(room.Metadata.IPAddresses))
+	for name, ip := range room.Metadata.IPAddresses {
+		addresses[name] = ip
+	}
+	room.Unlock()
+
+	room.Broadcast(NewRestartMessage(tournament.Round, tournament.Rounds))
+	for _, client := range clients {
+		if ip, ok := addresses[client.Name]; ok {
+			_ = client.Send(NewAssignedIPMessage(ip))
+		}
+	}
+	room.BroadcastMetadata()
+	room.BroadcastUserdata()
+	room.BroadcastGameState()
+	return nil
+}
+
+// RestartHandler lets the host move the tournament on to its next round
+// /room/{code}/restart?key=<key>
+func RestartHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+	rooms.RLock()
+	defer rooms.RUnlock()
+
+	// Get the room object
+	room, ok := rooms.Rooms[code]
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeHost(w, r, room) {
+		return
+	}
+
+	if err := room.Restart(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWithRules(t *testing.T) {
+	base := DefaultRoomOptions()
+	base.Rounds = 3
+	base.ChallengeTTL = 30
+	base.RoundRules = []json.RawMessage{json.RawMessage(`{"rounds": 9}`)}
+
+	tests := []struct {
+		name       string
+		rules      string
+		wantTTL    int
+		wantRounds int
+		wantErr    bool
+	}{
+		{"no rules", "", 30, 3, false},
+		{"rules override options", `{"challenge_ttl": 10}`, 10, 3, false},
+		{"the tournament can't be changed", `{"rounds": 9}`, 30, 3, false},
+		{"invalid json", `{"challenge_ttl": `, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := base.withRules(json.RawMessage(tt.rules))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withRules error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if next.ChallengeTTL != tt.wantTTL || next.Rounds != tt.wantRounds {
+				t.Errorf("got ttl %d and %d rounds, want %d and %d", next.ChallengeTTL, next.Rounds, tt.wantTTL, tt.wantRounds)
+			}
+		})
+	}
+}
+
+func TestValidateRounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		rounds  int
+		rules   []string
+		wantErr bool
+	}{
+		{"single game", 0, nil, false},
+		{"rules for every later round", 3, []string{`{}`, `{"challenge_ttl": 10}`}, false},
+		{"negative rounds", -1, nil, true},
+		{"too many rules", 2, []string{`{}`, `{}`}, true},
+		{"invalid rules", 2, []string{`{"challenge_ttl": -1}`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultRoomOptions()
+			opts.Rounds = tt.rounds
+			for _, rules := range tt.rules {
+				opts.RoundRules = append(opts.RoundRules, json.RawMessage(rules))
+			}
+			if err := opts.validateRounds(4); (err != nil) != tt.wantErr {
+				t.Errorf("validateRounds error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTournament creates a room playing the first round of a tournament
+func newTournament(t *testing.T, rounds int, rules ...string) *testRoom {
+	t.Helper()
+
+	room := newTestRoom(t, 4, func(opts *RoomOptions) {
+		opts.Rounds = rounds
+		for _, r := range rules {
+			opts.RoundRules = append(opts.RoundRules, json.RawMessage(r))
+		}
+	})
+	room.Lock()
+	room.BeginRound()
+	room.Unlock()
+	return room
+}
+
+// finishRound stops the room's current round
+func (room *testRoom) finishRound() {
+	room.Lock()
+	room.State.State = Stopped
+	room.EndRound()
+	room.Unlock()
+}
+
+func TestRestart(t *testing.T) {
+	tests := []struct {
+		name    string
+		rounds  int
+		played  int
+		stopped bool
+		want    error
+	}{
+		{"not a tournament", 0, 0, true, ErrNoTournament},
+		{"round still running", 2, 0, false, ErrRoundInProgress},
+		{"next round", 2, 0, true, nil},
+		{"tournament over", 2, 1, true, ErrTournamentOver},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTournament(t, tt.rounds)
+			for i := 0; i < tt.played; i++ {
+				room.finishRound()
+				if err := room.Restart(); err != nil {
+					t.Fatalf("Restart: %v", err)
+				}
+				room.State.State = Running
+			}
+			if tt.stopped {
+				room.finishRound()
+			}
+
+			if err := room.Restart(); !errors.Is(err, tt.want) {
+				t.Errorf("Restart error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartAppliesRules(t *testing.T) {
+	room := newTournament(t, 3, `{"challenge_ttl": 10, "coop_goal": 5}`, `{"challenge_ttl": 20}`)
+	room.request(t, room.players[0])
+
+	for round, want := range []int{10, 20} {
+		room.finishRound()
+		if err := room.Restart(); err != nil {
+			t.Fatalf("Restart: %v", err)
+		}
+
+		room.RLock()
+		if room.Options.ChallengeTTL != want {
+			t.Errorf("round %d: ChallengeTTL = %d, want %d", round+2, room.Options.ChallengeTTL, want)
+		}
+		if room.State.Goal != 5 || room.State.State != Waiting || room.State.Tournament.Round != round+2 {
+			t.Errorf("round %d: state = %+v", round+2, room.State)
+		}
+		if len(room.Challenges) != 0 {
+			t.Errorf("round %d: %d challenges were kept", round+2, len(room.Challenges))
+		}
+		room.RUnlock()
+
+		room.State.State = Running
+	}
+}
+
+func TestEndRound(t *testing.T) {
+	room := newTournament(t, 2)
+	sender := room.players[0]
+	challenge, _ := room.request(t, sender)
+	room.Answer(sender, AnswerMessage{Destination: challenge.DestIP, Question: challenge.Question, Answer: challenge.Answer})
+
+	room.finishRound()
+	tournament := room.State.Tournament
+	if len(tournament.Summaries) != 1 || tournament.Summaries[0].Winner != sender.Name {
+		t.Fatalf("summaries = %+v, want %s to win round 1", tournament.Summaries, sender.Name)
+	}
+	if tournament.Standings[sender.Name] != room.State.Scoreboard[sender.Name] {
+		t.Errorf("standings = %v, want the round's scores %v", tournament.Standings, room.State.Scoreboard)
+	}
+}
+
+func TestLifecycleHandlersNeedTheHostKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		state   RoomState
+		method  string
+		host    bool
+		status  int
+		want    RoomState
+	}{
+		{"host starts the game", StartHandler, Waiting, http.MethodPost, true, http.StatusOK, Starting},
+		{"start without the key", StartHandler, Waiting, http.MethodPost, false, http.StatusForbidden, Waiting},
+		{"host stops the game", StopHandler, Running, http.MethodPost, true, http.StatusOK, Stopping},
+		{"stop without the key", StopHandler, Running, http.MethodPost, false, http.StatusForbidden, Running},
+		{"stop over GET", StopHandler, Running, http.MethodGet, true, http.StatusMethodNotAllowed, Running},
+		{"next round without the key", RestartHandler, Stopped, http.MethodPost, false, http.StatusForbidden, Stopped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, 2, nil)
+			room.State.State = tt.state
+
+			w := room.serve(t, tt.handler, tt.method, tt.host, "", "", nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			room.RLock()
+			defer room.RUnlock()
+			if room.State.State != tt.want {
+				t.Errorf("state = %d, want %d", room.State.State, tt.want)
+			}
+		})
+	}
+}